@@ -0,0 +1,132 @@
+package fastlystats
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/fastly/go-fastly/v3/fastly"
+)
+
+// Aggregation decides how the per-second samples of a field are combined
+// into the single value published for an interval.
+type Aggregation string
+
+const (
+	AggregateSum  = Aggregation("sum")
+	AggregateMean = Aggregation("mean")
+	AggregateMax  = Aggregation("max")
+	AggregateMin  = Aggregation("min")
+	AggregateLast = Aggregation("last")
+)
+
+func ParseAggregation(s string) (Aggregation, error) {
+	switch a := Aggregation(s); a {
+	case AggregateSum, AggregateMean, AggregateMax, AggregateMin, AggregateLast:
+		return a, nil
+	}
+
+	return "", fmt.Errorf("invalid aggregation %q, must be one of sum, mean, max, min or last", s)
+}
+
+// DefaultAggregation returns the aggregation of a field when none is
+// configured, as given by its catalog entry. Fields without one are averaged.
+func DefaultAggregation(name string) Aggregation {
+	if e, err := getCatalogEntry(name); err == nil && e.Aggregation != "" {
+		return e.Aggregation
+	}
+
+	return AggregateMean
+}
+
+// StatValues holds the value of every numeric stats field keyed by metric
+// name, without rounding integer fields.
+type StatValues map[string]float64
+
+// aggregate combines the samples of one field over n seconds. There may be
+// fewer than n samples, missing seconds count as zero.
+func aggregate(a Aggregation, samples []float64, n uint64) float64 {
+	missing := uint64(len(samples)) < n
+
+	switch a {
+	case AggregateSum:
+		var sum float64
+		for _, v := range samples {
+			sum += v
+		}
+		return sum
+
+	case AggregateMax:
+		max := math.Inf(-1)
+		if missing || len(samples) == 0 {
+			max = 0
+		}
+		for _, v := range samples {
+			max = math.Max(max, v)
+		}
+		return max
+
+	case AggregateMin:
+		min := math.Inf(1)
+		if missing || len(samples) == 0 {
+			min = 0
+		}
+		for _, v := range samples {
+			min = math.Min(min, v)
+		}
+		return min
+
+	case AggregateLast:
+		if len(samples) == 0 {
+			return 0
+		}
+		return samples[len(samples)-1]
+
+	default:
+		var sum float64
+		for _, v := range samples {
+			sum += v
+		}
+		return sum / float64(n)
+	}
+}
+
+// aggregateOf combines every field over n seconds using the configured
+// aggregations. A field missing from a sample counts as zero for that
+// second. It returns the result both as fastly.Stats, with integer fields
+// rounded, and as unrounded StatValues, along with the total of every field
+// over the interval.
+func aggregateOf(list []StatValues, n uint64, aggregations map[string]Aggregation) (*fastly.Stats, StatValues, StatValues) {
+	names := map[string]bool{}
+	for _, sample := range list {
+		for name := range sample {
+			names[name] = true
+		}
+	}
+
+	values := make(StatValues, len(names))
+	totals := make(StatValues, len(names))
+
+	samples := make([]float64, 0, len(list))
+	for name := range names {
+		samples = samples[:0]
+		for _, sample := range list {
+			if v, ok := sample[name]; ok {
+				samples = append(samples, v)
+			}
+		}
+
+		a, ok := aggregations[name]
+		if !ok {
+			a = DefaultAggregation(name)
+		}
+
+		values[name] = aggregate(a, samples, n)
+		totals[name] = aggregate(AggregateSum, samples, n)
+	}
+
+	// Hit Ratio is not set in RT API, build it synthetically
+	values["hit_ratio"] = values["hits"] / (values["hits"] + values["miss"])
+	totals["hit_ratio"] = totals["hits"] / (totals["hits"] + totals["miss"])
+
+	return statsOf(values), values, totals
+}
@@ -0,0 +1,170 @@
+package fastlystats
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/fastly/go-fastly/v3/fastly"
+	"github.com/mitchellh/mapstructure"
+	"go.uber.org/zap"
+)
+
+// RealtimeRetention is roughly how far back the realtime API serves data.
+// Anything older can only be recovered from the historical stats.
+const RealtimeRetention = 2 * time.Minute
+
+// Backfiller fills gaps in the realtime stats from the historical stats API,
+// which keeps stats per minute.
+type Backfiller struct {
+	fastlyClient *fastly.Client
+	opts         ProviderOptions
+	ch           chan<- *FastlyMeanStats
+}
+
+func NewBackfiller(apiKey string, opts ProviderOptions, ch chan<- *FastlyMeanStats) (*Backfiller, error) {
+	fastlyClient, err := fastly.NewClient(apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Backfiller{
+		fastlyClient: fastlyClient,
+		opts:         opts,
+		ch:           ch,
+	}, nil
+}
+
+// historicalStats is the response of the historical stats API. The stats are
+// decoded separately, as the start of every minute is not part of fastly.Stats.
+type historicalStats struct {
+	Status  string                   `json:"status"`
+	Message string                   `json:"msg"`
+	Data    []map[string]interface{} `json:"data"`
+}
+
+// Backfill publishes a snapshot for every complete minute of service after
+// the second after and up to the second until, both unix timestamps. Minutes
+// the historical stats do not have yet are left out. It returns the last
+// second covered, which is after if nothing was published.
+func (b *Backfiller) Backfill(ctx context.Context, service string, after, until uint64) (uint64, error) {
+	ll := zap.S().With("service", service)
+
+	from := (after/60 + 1) * 60
+	to := (until + 1) / 60 * 60
+	if from >= to {
+		return after, nil
+	}
+
+	ll.Infof("backfilling %d minutes from %s to %s",
+		(to-from)/60, time.Unix(int64(from), 0).UTC().Format(time.RFC3339), time.Unix(int64(to), 0).UTC().Format(time.RFC3339))
+
+	var resp historicalStats
+	err := b.fastlyClient.GetStatsJSON(&fastly.GetStatsInput{
+		Service: service,
+		From:    strconv.FormatUint(from, 10),
+		To:      strconv.FormatUint(to, 10),
+		By:      "minute",
+	}, &resp)
+	if err != nil {
+		return after, err
+	}
+	if resp.Status != "success" {
+		return after, fmt.Errorf("historical stats: %s %s", resp.Status, resp.Message)
+	}
+
+	last := after
+	for _, data := range resp.Data {
+		start, minute, err := decodeHistoricalStats(data)
+		if err != nil {
+			return last, err
+		}
+		if start < from || start+60 > to || start <= last {
+			continue
+		}
+
+		snapshot := b.snapshot(service, start, minute)
+		snapshot.Lag = time.Since(time.Unix(int64(snapshot.IntervalEnd)+1, 0))
+		select {
+		case b.ch <- snapshot:
+		case <-ctx.Done():
+			return last, ctx.Err()
+		}
+		last = snapshot.IntervalEnd
+	}
+
+	ll.Infof("backfilled up to %s", time.Unix(int64(last), 0).UTC().Format(time.RFC3339))
+
+	return last, nil
+}
+
+func decodeHistoricalStats(data map[string]interface{}) (uint64, *fastly.Stats, error) {
+	var start uint64
+	stats := &fastly.Stats{}
+
+	for _, d := range []struct {
+		in  interface{}
+		out interface{}
+	}{
+		{data["start_time"], &start},
+		{data, stats},
+	} {
+		decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+			WeaklyTypedInput: true,
+			Result:           d.out,
+		})
+		if err != nil {
+			return 0, nil, err
+		}
+		if err := decoder.Decode(d.in); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	if start == 0 {
+		return 0, nil, fmt.Errorf("historical stats without start_time")
+	}
+
+	return start, stats, nil
+}
+
+// snapshot turns the totals of one minute into a snapshot like the provider
+// publishes. The per-second samples are unknown, so the minute is assumed to
+// be evenly spread: summed fields keep the total, every other aggregation
+// gets the average per second.
+func (b *Backfiller) snapshot(service string, start uint64, minute *fastly.Stats) *FastlyMeanStats {
+	const seconds = 60
+
+	totals := statValuesOf(minute)
+	values := make(StatValues, len(totals))
+	for name, total := range totals {
+		a, ok := b.opts.Aggregations[name]
+		if !ok {
+			a = DefaultAggregation(name)
+		}
+
+		values[name] = total / seconds
+		if a == AggregateSum {
+			values[name] = total
+		}
+	}
+
+	values["hit_ratio"] = values["hits"] / (values["hits"] + values["miss"])
+	totals["hit_ratio"] = totals["hits"] / (totals["hits"] + totals["miss"])
+	stats := statsOf(values)
+
+	snapshot := &FastlyMeanStats{
+		Service:       service,
+		Backfilled:    true,
+		IntervalStart: start,
+		IntervalEnd:   start + seconds - 1,
+		Stats:         stats,
+		Values:        values,
+		Totals:        totals,
+		MissHistogram: mergeMissHistograms([]*fastly.Stats{minute}),
+	}
+	snapshot.derive(b.opts.DerivedMetrics)
+
+	return snapshot
+}
@@ -0,0 +1,123 @@
+package fastlystats
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// backoff produces exponentially growing delays with jitter, starting at
+// initial and never exceeding max.
+type backoff struct {
+	initial time.Duration
+	max     time.Duration
+
+	attempt int
+}
+
+// next returns the delay before the next attempt. The delay is picked at
+// random between half and all of the exponential delay, so that many clients
+// failing at once do not retry in lockstep.
+func (b *backoff) next() time.Duration {
+	d := b.initial << b.attempt
+	if d <= 0 || d > b.max {
+		d = b.max
+	} else {
+		b.attempt++
+	}
+
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func (b *backoff) reset() {
+	b.attempt = 0
+}
+
+// sleep waits for d, or returns the context error if it is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retryableError marks an error as transient. retryAfter is the delay the
+// server asked for, if any.
+type retryableError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+// isRetryable reports whether err is transient, and the delay asked for by
+// the server, if any.
+func isRetryable(err error) (bool, time.Duration) {
+	var re *retryableError
+	if errors.As(err, &re) {
+		return true, re.retryAfter
+	}
+
+	return false, 0
+}
+
+// parseRetryAfter reads a Retry-After header, given either in seconds or as
+// an HTTP date. It returns 0 if the header is missing or invalid.
+func parseRetryAfter(h http.Header) time.Duration {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
+
+// parseRateLimitReset reads the Fastly-RateLimit-Reset header, the unix time
+// at which the rate limit window resets, as a delay from now. It returns 0 if
+// the header is missing, invalid or in the past.
+func parseRateLimitReset(h http.Header) time.Duration {
+	reset, err := strconv.ParseInt(h.Get("Fastly-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	if d := time.Until(time.Unix(reset, 0)); d > 0 {
+		return d
+	}
+
+	return 0
+}
+
+// rateLimitWait returns how long to wait before the next request when the
+// Fastly rate limit has been used up, or 0 if there is quota left.
+func rateLimitWait(h http.Header) time.Duration {
+	if h.Get("Fastly-RateLimit-Remaining") != "0" {
+		return 0
+	}
+
+	return parseRateLimitReset(h)
+}
@@ -0,0 +1,771 @@
+package fastlystats
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/api/metric"
+)
+
+// MetricKind tells how the values of a field relate to each other over time.
+type MetricKind string
+
+const (
+	// KindCounter counts events, bytes or time spent per second, so it can be
+	// summed over an interval.
+	KindCounter = MetricKind("counter")
+	// KindGauge is a ratio or level, which can only be averaged.
+	KindGauge = MetricKind("gauge")
+)
+
+// CatalogEntry describes a field of the realtime stats. Every sink, and the
+// Stackdriver metric descriptors, are derived from it.
+type CatalogEntry struct {
+	Name        string
+	DisplayName string
+	Unit        string
+	Description string
+	Kind        MetricKind
+	// Aggregation is used unless another one is configured for the field.
+	Aggregation Aggregation
+	// Enabled fields are exported, disabled ones are left out by every sink.
+	Enabled bool
+}
+
+// catalogIndex indexes MetricCatalog by name.
+var catalogIndex = func() map[string]CatalogEntry {
+	index := make(map[string]CatalogEntry, len(MetricCatalog))
+	for _, e := range MetricCatalog {
+		index[e.Name] = e
+	}
+
+	return index
+}()
+
+func getCatalogEntry(name string) (CatalogEntry, error) {
+	if e, ok := catalogIndex[name]; ok {
+		return e, nil
+	}
+
+	return CatalogEntry{}, ErrNotFound
+}
+
+// AddCatalogEntry adds a metric that is not a field of the realtime stats,
+// such as a derived metric, to the catalog and the descriptors derived from
+// it. It must be called before any provider or exporter is started.
+func AddCatalogEntry(e CatalogEntry) error {
+	if _, ok := catalogIndex[e.Name]; ok {
+		return fmt.Errorf("metric %s is in the catalog already", e.Name)
+	}
+	if _, ok := statFields[e.Name]; ok {
+		return fmt.Errorf("metric %s is a field of the realtime stats", e.Name)
+	}
+	if isReservedMetric(e.Name) {
+		return fmt.Errorf("metric %s is exported by the exporter itself", e.Name)
+	}
+
+	MetricCatalog = append(MetricCatalog, e)
+	catalogIndex[e.Name] = e
+	MetricDescriptors = append(MetricDescriptors, metricDescriptorOf(e))
+	NRMetricDescriptors = append(NRMetricDescriptors, nrMetricDescriptorOf(e))
+
+	return nil
+}
+
+// isEnabled reports whether a field is exported. Fields without a catalog
+// entry are, so that new fields show up without a release.
+func isEnabled(name string) bool {
+	e, err := getCatalogEntry(name)
+	return err != nil || e.Enabled
+}
+
+// isReservedMetric reports whether name is taken by a metric that is not a
+// field, such as the feed and miss latency metrics or a distribution.
+func isReservedMetric(name string) bool {
+	for _, descriptors := range [][]*metric.MetricDescriptor{feedDescriptors, missLatencyDescriptors} {
+		for _, md := range descriptors {
+			if md.Name == name {
+				return true
+			}
+		}
+	}
+
+	for _, other := range otherMetricNames {
+		if name == other {
+			return true
+		}
+	}
+
+	return strings.HasSuffix(name, "_distribution")
+}
+
+// otherMetricNames are the names sinks give the feed and miss latency
+// metrics besides those of their descriptors.
+var otherMetricNames = []string{"miss_latency", "miss_latency_ms", "data_lag_seconds"}
+
+// CheckCatalog returns the numeric fields of fastly.Stats without a catalog
+// entry, sorted. They are exported as gauges without a unit or description.
+func CheckCatalog() []string {
+	var missing []string
+	for name := range statFields {
+		if _, ok := catalogIndex[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+
+	return missing
+}
+
+// MetricCatalog lists every known field of the realtime stats.
+var MetricCatalog = []CatalogEntry{
+	{
+		Name:        "requests",
+		DisplayName: "Request Count",
+		Unit:        "1/s",
+		Description: "Number of requests processed.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "hits",
+		DisplayName: "Cache Hits",
+		Unit:        "1/s",
+		Description: "Number of cache hits.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "hits_time",
+		DisplayName: "Hits Time",
+		Unit:        "s",
+		Description: "Total amount of time spent processing cache hits (in seconds).",
+		Kind:        KindCounter,
+		Aggregation: AggregateSum,
+		Enabled:     true,
+	},
+	{
+		Name:        "miss",
+		DisplayName: "Cache Miss",
+		Unit:        "1/s",
+		Description: "Number of cache misses.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "miss_time",
+		DisplayName: "Miss Time",
+		Unit:        "s",
+		Description: "Amount of time spent processing cache misses (in seconds).",
+		Kind:        KindCounter,
+		Aggregation: AggregateSum,
+		Enabled:     true,
+	},
+	{
+		Name:        "pass",
+		DisplayName: "Cache Pass",
+		Unit:        "1/s",
+		Description: "Number of requests that passed through the CDN without being cached.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "pass_time",
+		DisplayName: "Pass Time",
+		Unit:        "s",
+		Description: "Amount of time spent processing cache misses (in seconds).",
+		Kind:        KindCounter,
+		Aggregation: AggregateSum,
+		Enabled:     true,
+	},
+	{
+		Name:        "synth",
+		DisplayName: "Synth",
+		Unit:        "1/s",
+		Description: "Number of requests that returned synth response.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "errors",
+		DisplayName: "Errors",
+		Unit:        "1/s",
+		Description: "Number of cache errors.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "restarts",
+		DisplayName: "Restarts",
+		Unit:        "1/s",
+		Description: "Number of restarts performed.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "hit_ratio",
+		DisplayName: "Cache Hit Ratio",
+		Unit:        "10^2.%",
+		Description: "Global Cache Hit ratio, defined as hits/requests",
+		Kind:        KindGauge,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "bandwidth",
+		DisplayName: "Bandwidth",
+		Unit:        "By/s",
+		Description: "Total bytes delivered (body_size + header_size).",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "req_body_bytes",
+		DisplayName: "Request Body Bytes",
+		Unit:        "By/s",
+		Description: "Total body bytes received.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "req_header_bytes",
+		DisplayName: "Request Header Bytes",
+		Unit:        "By/s",
+		Description: "Total header bytes received.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "resp_body_bytes",
+		DisplayName: "Response Body Bytes",
+		Unit:        "By/s",
+		Description: "Total body bytes delivered.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "resp_header_bytes",
+		DisplayName: "Response Header Bytes",
+		Unit:        "By/s",
+		Description: "Total header bytes delivered.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "bereq_body_bytes",
+		DisplayName: "Backend Request Body Bytes",
+		Unit:        "By/s",
+		Description: "Total body bytes sent to origin.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "bereq_header_bytes",
+		DisplayName: "Backend Request Header Bytes",
+		Unit:        "By/s",
+		Description: "Total header bytes sent to origin.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "uncachable",
+		DisplayName: "Uncachable",
+		Unit:        "By/s",
+		Description: "Number of requests that were designated uncachable.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "pipe",
+		DisplayName: "Pipe",
+		Unit:        "1/s",
+		Description: "Optional. Pipe operations performed (legacy feature).",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "tls",
+		DisplayName: "TLS",
+		Unit:        "1/s",
+		Description: "Number of requests that were received over TLS.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "tls_v10",
+		DisplayName: "TLS v1.0",
+		Unit:        "1/s",
+		Description: "Number of requests received over TLS 1.0.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "tls_v11",
+		DisplayName: "TLS v1.1",
+		Unit:        "1/s",
+		Description: "Number of requests received over TLS 1.1.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "tls_v12",
+		DisplayName: "TLS v1.2",
+		Unit:        "1/s",
+		Description: "Number of requests received over TLS 1.2.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "tls_v13",
+		DisplayName: "TLS v1.3",
+		Unit:        "1/s",
+		Description: "Number of requests received over TLS 1.3.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "shield",
+		DisplayName: "Shield",
+		Unit:        "1/s",
+		Description: "Number of requests from shield to origin.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "shield_resp_body_bytes",
+		DisplayName: "Shield Response Body Bytes",
+		Unit:        "By/s",
+		Description: "Total body bytes delivered via a shield.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "shield_resp_header_bytes",
+		DisplayName: "Shield Response Header Bytes",
+		Unit:        "By/s",
+		Description: "Total header bytes delivered via a shield.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "ipv6",
+		DisplayName: "IPv6",
+		Unit:        "1/s",
+		Description: "Number of requests that were received over IPv6.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "otfp",
+		DisplayName: "On-The-Fly Packing",
+		Unit:        "1/s",
+		Description: "Number of responses that came from the Fastly On-the-Fly Packager for On Demand Streaming service for video-on-demand.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "video",
+		DisplayName: "Video Responses",
+		Unit:        "1/s",
+		Description: "Number of responses with a video segment or video manifest MIME type.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "pci",
+		DisplayName: "PCI",
+		Unit:        "1/s",
+		Description: "Number of responses with the PCI flag turned on.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "log",
+		DisplayName: "log",
+		Unit:        "1/s",
+		Description: "Number of log lines sent.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "http2",
+		DisplayName: "HTTP/2",
+		Unit:        "1/s",
+		Description: "Number of requests received over HTTP2.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "waf_logged",
+		DisplayName: "Web Application Firewall Logged",
+		Unit:        "1/s",
+		Description: "Number of requests that triggered a WAF rule and were logged.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "waf_blocked",
+		DisplayName: "Web Application Firewall Blocked",
+		Unit:        "1/s",
+		Description: "Number of requests that triggered a WAF rule and were blocked.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "waf_passed",
+		DisplayName: "Web Application Firewall Passed",
+		Unit:        "1/s",
+		Description: "Number of requests that triggered a WAF rule and were passed.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "attack_req_body_bytes",
+		DisplayName: "Attack Request Body Bytes",
+		Unit:        "By/s",
+		Description: "Number of requests that triggered a WAF rule and were passed.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "attack_req_header_bytes",
+		DisplayName: "Attack Request Header Bytes",
+		Unit:        "By/s",
+		Description: "Number of requests that triggered a WAF rule and were passed.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "attack_resp_synth_bytes",
+		DisplayName: "Attack Response Synthetic Bytes",
+		Unit:        "By/s",
+		Description: "Total bytes delivered for requests that triggered a WAF rule and returned a synthetic response.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "imgopto",
+		DisplayName: "Image Optimizer",
+		Unit:        "1/s",
+		Description: "Number of responses that came from the Fastly Image Optimizer service.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "status_200",
+		DisplayName: "HTTP Status 200",
+		Unit:        "1/s",
+		Description: "Number of responses sent with status code 200 (Success).",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "status_204",
+		DisplayName: "HTTP Status 204",
+		Unit:        "1/s",
+		Description: "Number of responses sent with status code 204 (No Content).",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "status_206",
+		DisplayName: "HTTP Status 206",
+		Unit:        "1/s",
+		Description: "Number of responses sent with status code 206 (Partial Content).",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "status_301",
+		DisplayName: "HTTP Status 301",
+		Unit:        "1/s",
+		Description: "Number of responses sent with status code 301 (Moved Permanently).",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "status_302",
+		DisplayName: "HTTP Status 302",
+		Unit:        "1/s",
+		Description: "Number of responses sent with status code 302 (Found).",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "status_304",
+		DisplayName: "HTTP Status 304",
+		Unit:        "1/s",
+		Description: "Number of responses sent with status code 304 (Not Modified).",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "status_400",
+		DisplayName: "HTTP Status 400",
+		Unit:        "1/s",
+		Description: "Number of responses sent with status code 400 (Bad Request).",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "status_401",
+		DisplayName: "HTTP Status 401",
+		Unit:        "1/s",
+		Description: "Number of responses sent with status code 401 (Unauthorized).",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "status_403",
+		DisplayName: "HTTP Status 403",
+		Unit:        "1/s",
+		Description: "Number of responses sent with status code 403 (Forbidden).",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "status_404",
+		DisplayName: "HTTP Status 404",
+		Unit:        "1/s",
+		Description: "Number of responses sent with status code 404 (Not Found).",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "status_416",
+		DisplayName: "HTTP Status 416",
+		Unit:        "1/s",
+		Description: "Number of responses sent with status code 416 (Range Not Satisfiable).",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "status_500",
+		DisplayName: "HTTP Status 500",
+		Unit:        "1/s",
+		Description: "Number of responses sent with status code 500 (Internal Server Error).",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "status_501",
+		DisplayName: "HTTP Status 501",
+		Unit:        "1/s",
+		Description: "Number of responses sent with status code 501 (Not Implemented).",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "status_502",
+		DisplayName: "HTTP Status 502",
+		Unit:        "1/s",
+		Description: "Number of responses sent with status code 502 (Bad Gateway).",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "status_503",
+		DisplayName: "HTTP Status 503",
+		Unit:        "1/s",
+		Description: "Number of responses sent with status code 503 (Service Unavailable).",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "status_504",
+		DisplayName: "HTTP Status 504",
+		Unit:        "1/s",
+		Description: "Number of responses sent with status code 504 (Gateway Timeout).",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "status_505",
+		DisplayName: "HTTP Status 505",
+		Unit:        "1/s",
+		Description: "Number of responses sent with status code 505 (HTTP Version Not Supported).",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "status_1xx",
+		DisplayName: "HTTP Status 1xx",
+		Unit:        "1/s",
+		Description: "Number of \"Informational\" status codes delivered.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "status_2xx",
+		DisplayName: "HTTP Status 2xx",
+		Unit:        "1/s",
+		Description: "Number of \"Success\" status codes delivered.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "status_3xx",
+		DisplayName: "HTTP Status 3xx",
+		Unit:        "1/s",
+		Description: "Number of \"Redirection\" status codes delivered.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "status_4xx",
+		DisplayName: "HTTP Status 4xx",
+		Unit:        "1/s",
+		Description: "Number of \"Client Error\" status codes delivered.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "status_5xx",
+		DisplayName: "HTTP Status 5xx",
+		Unit:        "1/s",
+		Description: "Number of \"Server Error\" status codes delivered.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "object_size_1k",
+		DisplayName: "Object Size 1KB",
+		Unit:        "1/s",
+		Description: "Number of objects served that were under 1KB in size.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "object_size_10k",
+		DisplayName: "Object Size 10KB",
+		Unit:        "1/s",
+		Description: "Number of objects served that were between 1KB and 10KB in size.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "object_size_100k",
+		DisplayName: "Object Size 100KB",
+		Unit:        "1/s",
+		Description: "Number of objects served that were between 10KB and 100KB in size.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "object_size_1m",
+		DisplayName: "Object Size 1MB",
+		Unit:        "1/s",
+		Description: "Number of objects served that were between 100KB and 1MB in size.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "object_size_10m",
+		DisplayName: "Object Size 10MB",
+		Unit:        "1/s",
+		Description: "Number of objects served that were between 1MB and 10MB in size.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "object_size_100m",
+		DisplayName: "Object Size 100MB",
+		Unit:        "1/s",
+		Description: "Number of objects served that were between 10MB and 100MB in size.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "object_size_1g",
+		DisplayName: "Object Size 1GB",
+		Unit:        "1/s",
+		Description: "Number of objects served that were between 100MB and 1GB in size.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "billed_header_bytes",
+		DisplayName: "Billed Header Bytes",
+		Unit:        "By/s",
+		Description: "Number of header bytes used as basis for billing.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+	{
+		Name:        "billed_body_bytes",
+		DisplayName: "Billed Body Bytes",
+		Unit:        "By/s",
+		Description: "Number of body bytes used as basis for billing.",
+		Kind:        KindCounter,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	},
+}
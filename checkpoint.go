@@ -0,0 +1,83 @@
+package fastlystats
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CheckpointStore persists the last processed realtime timestamp of every
+// service, so that a provider can resume where it left off after a restart.
+type CheckpointStore interface {
+	// Load returns the checkpoint of service, or 0 if there is none.
+	Load(service string) (uint64, error)
+	Save(service string, timestamp uint64) error
+}
+
+// FileCheckpointStore keeps the checkpoints of all services in one JSON file.
+type FileCheckpointStore struct {
+	path string
+
+	mu          sync.Mutex
+	checkpoints map[string]uint64
+}
+
+func NewFileCheckpointStore(path string) (*FileCheckpointStore, error) {
+	s := &FileCheckpointStore{
+		path:        path,
+		checkpoints: map[string]uint64{},
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, &s.checkpoints); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *FileCheckpointStore) Load(service string) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.checkpoints[service], nil
+}
+
+// Save updates the checkpoint of service and rewrites the file. The file is
+// replaced atomically, so a crash never leaves it half written.
+func (s *FileCheckpointStore) Save(service string, timestamp uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.checkpoints[service] = timestamp
+
+	b, err := json.Marshal(s.checkpoints)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
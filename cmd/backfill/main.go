@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	fastlystats "github.com/Storytel/fastly-stackdriver-exporter"
+	"github.com/joho/godotenv"
+	"github.com/sethvargo/go-envconfig"
+	"go.uber.org/zap"
+)
+
+func init() {
+	if err := godotenv.Load(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+var outputJson bool
+var googleCloudProject string
+var configFile string
+var services string
+var from string
+var to string
+
+func main() {
+	flag.BoolVar(&outputJson, "output-json", false, "Whether output should be JSON encoded")
+	flag.StringVar(&googleCloudProject, "project", "", "The Google Cloud Project to report to, overrides env GOOGLE_CLOUD_PROJECT")
+	flag.StringVar(&configFile, "config", "", "Additional env file to read configuration from")
+	flag.StringVar(&services, "services", "", "Comma separated services to backfill, defaults to the configured services")
+	flag.StringVar(&from, "from", "", "Start of the gap (RFC 3339), defaults to the checkpoint of each service in env CHECKPOINT_FILE")
+	flag.StringVar(&to, "to", "", "End of the gap (RFC 3339), defaults to the oldest data the realtime API still has")
+	flag.Parse()
+
+	if configFile != "" {
+		if err := godotenv.Load(configFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	logger, _ := zap.NewDevelopment()
+	if outputJson {
+		logger, _ = zap.NewProduction(zap.IncreaseLevel(zap.InfoLevel))
+	}
+	zap.ReplaceGlobals(logger)
+
+	ll := logger.Sugar()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
+		closeSignal := <-ch
+
+		ll.Infof("Received close signal (%v), terminating.", closeSignal)
+		cancel()
+	}()
+
+	cfg := &fastlystats.Config{}
+	if err := envconfig.Process(ctx, cfg); err != nil {
+		ll.Fatal(err)
+	}
+
+	if googleCloudProject != "" {
+		cfg.GoogleCloudProject = googleCloudProject
+	}
+
+	if cfg.FastlyAPIKey == "" {
+		ll.Fatal("Fastly API key missing, set env FASTLY_API_KEY")
+	}
+
+	serviceList := cfg.Services()
+	if services != "" {
+		serviceList = strings.Split(services, ",")
+	}
+	if len(serviceList) == 0 {
+		ll.Fatal("No services to backfill, set -services or env FASTLY_SERVICE or FASTLY_SERVICES")
+	}
+
+	if err := cfg.AddDerivedMetrics(); err != nil {
+		ll.Fatalf("Bad derived metrics: %v", err)
+	}
+
+	providerOptions, err := cfg.ProviderOptions()
+	if err != nil {
+		ll.Fatalf("Bad provider configuration: %v", err)
+	}
+
+	until := uint64(time.Now().Add(-fastlystats.RealtimeRetention).Unix())
+	if to != "" {
+		t, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			ll.Fatalf("Bad -to: %v", err)
+		}
+		until = uint64(t.Unix())
+	}
+
+	after := map[string]uint64{}
+	for _, service := range serviceList {
+		switch {
+		case from != "":
+			t, err := time.Parse(time.RFC3339, from)
+			if err != nil {
+				ll.Fatalf("Bad -from: %v", err)
+			}
+			after[service] = uint64(t.Unix()) - 1
+		case providerOptions.Checkpoints != nil:
+			ts, err := providerOptions.Checkpoints.Load(service)
+			if err != nil {
+				ll.Fatalf("Failed to load checkpoint of service %s: %v", service, err)
+			}
+			if ts == 0 {
+				ll.Fatalf("No checkpoint for service %s, set -from", service)
+			}
+			after[service] = ts
+		default:
+			ll.Fatal("Start of the gap unknown, set -from or env CHECKPOINT_FILE")
+		}
+	}
+
+	ch := make(chan *fastlystats.FastlyMeanStats)
+	backfiller, err := fastlystats.NewBackfiller(cfg.FastlyAPIKey, providerOptions, ch)
+	if err != nil {
+		ll.Fatal(err)
+	}
+
+	sanitizePolicy, err := fastlystats.ParseSanitizePolicy(cfg.SanitizePolicy)
+	if err != nil {
+		ll.Fatalf("Bad SANITIZE_POLICY: %v", err)
+	}
+	sanitized := make(chan *fastlystats.FastlyMeanStats)
+	sanitizer := fastlystats.NewSanitizer(sanitizePolicy, ch, sanitized)
+
+	// Every minute is pushed as fast as the sinks take them, none may be
+	// dropped
+	cfg.SinkBackpressurePolicy = string(fastlystats.Block)
+	for sink := range cfg.SinkBackpressurePolicies {
+		cfg.SinkBackpressurePolicies[sink] = string(fastlystats.Block)
+	}
+
+	fanout := fastlystats.NewFanout(sanitized)
+	exporters, sinks, err := fastlystats.NewSinks(cfg, fanout)
+	if err != nil {
+		ll.Fatal(err)
+	}
+	if len(exporters) == 0 {
+		ll.Fatalf("No sinks enabled (available: %s)", strings.Join(fastlystats.Exporters(), ", "))
+	}
+	ll.Infof("Enabled sinks: %s", strings.Join(sinks, ", "))
+
+	go sanitizer.Run(ctx)
+	go fanout.Run(ctx)
+
+	wg := sync.WaitGroup{}
+	wg.Add(len(exporters))
+	for _, exporter := range exporters {
+		go func(exporter fastlystats.Exporter) {
+			defer wg.Done()
+			exporter.Run(ctx)
+		}(exporter)
+	}
+
+	for _, service := range serviceList {
+		if _, err := backfiller.Backfill(ctx, service, after[service], until); err != nil {
+			ll.Errorf("Failed to backfill service %s: %v", service, err)
+		}
+	}
+
+	// Closing the input drains the sanitizer and the sinks before they exit
+	close(ch)
+	wg.Wait()
+}
@@ -6,8 +6,11 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	fastlystats "github.com/Storytel/fastly-stackdriver-exporter"
 	"github.com/joho/godotenv"
@@ -21,29 +24,30 @@ func init() {
 	}
 }
 
+// statsProvider polls Fastly for one or more services.
+type statsProvider interface {
+	Run(ctx context.Context)
+	fastlystats.StatusReporter
+}
+
 var rebuildMetricDescriptors bool
 var outputJson bool
 var googleCloudProject string
-
-func multiplexChannel(ctx context.Context, ch <-chan *fastlystats.FastlyMeanStats, consumers []chan *fastlystats.FastlyMeanStats) {
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case stats := <-ch:
-			for _, c := range consumers {
-				c <- stats
-			}
-		}
-	}
-}
+var configFile string
 
 func main() {
 	flag.BoolVar(&outputJson, "output-json", false, "Whether output should be JSON encoded")
 	flag.BoolVar(&rebuildMetricDescriptors, "rebuild-metric-descriptors", false, "Re-build all metric descriptors and exit")
-	flag.StringVar(&googleCloudProject, "project", "", "The Google Cloud Project to delete metrics from")
+	flag.StringVar(&googleCloudProject, "project", "", "The Google Cloud Project to report to, overrides env GOOGLE_CLOUD_PROJECT")
+	flag.StringVar(&configFile, "config", "", "Additional env file to read configuration from")
 	flag.Parse()
 
+	if configFile != "" {
+		if err := godotenv.Load(configFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	logger, _ := zap.NewDevelopment()
 	if outputJson {
 		logger, _ = zap.NewProduction(zap.IncreaseLevel(zap.InfoLevel))
@@ -52,10 +56,6 @@ func main() {
 
 	ll := logger.Sugar()
 
-	if googleCloudProject == "" {
-		ll.Fatal("Specify Google Cloud Project with the -project flag.")
-	}
-
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -72,81 +72,133 @@ func main() {
 		os.Exit(2)
 	}()
 
-	if rebuildMetricDescriptors {
-		fastlystats.SetupMetricDescriptors(ctx, googleCloudProject)
-		return
-	}
-
 	cfg := &fastlystats.Config{}
 	if err := envconfig.Process(ctx, cfg); err != nil {
 		ll.Fatal(err)
 	}
 
+	if googleCloudProject != "" {
+		cfg.GoogleCloudProject = googleCloudProject
+	}
+
+	if missing := fastlystats.CheckCatalog(); len(missing) > 0 {
+		ll.Warnf("Fastly stats fields without a catalog entry, exported without unit or description: %s", strings.Join(missing, ", "))
+	}
+
+	if err := cfg.AddDerivedMetrics(); err != nil {
+		ll.Fatalf("Bad derived metrics: %v", err)
+	}
+
+	if rebuildMetricDescriptors {
+		if cfg.GoogleCloudProject == "" {
+			ll.Fatal("Specify Google Cloud Project with the -project flag or env GOOGLE_CLOUD_PROJECT.")
+		}
+		exportOptions, err := cfg.ExportOptions("stackdriver")
+		if err != nil {
+			ll.Fatal(err)
+		}
+		fastlystats.SetupMetricDescriptors(ctx, cfg.GoogleCloudProject, exportOptions)
+		return
+	}
+
 	if cfg.FastlyAPIKey == "" {
 		ll.Fatal("Fastly API key missing, set env FASTLY_API_KEY")
 	}
 
-	if cfg.FastlyService == "" {
-		ll.Fatal("Fastly Service is missing, set env FASTLY_SERVICE")
+	if cfg.FastlyPollInterval < time.Second {
+		ll.Fatalf("FASTLY_POLL_INTERVAL must be at least 1s, got %v", cfg.FastlyPollInterval)
 	}
 
-	var (
-		useStackdriver = true
-		useNewRelic    = cfg.NewRelicInsertKey != ""
-	)
-
-	ch := make(chan *fastlystats.FastlyMeanStats)
+	services := cfg.Services()
+	if len(services) == 0 && !cfg.FastlyDiscoverServices {
+		ll.Fatal("Fastly Service is missing, set env FASTLY_SERVICE or FASTLY_SERVICES, or enable FASTLY_DISCOVER_SERVICES")
+	}
 
-	provider, err := fastlystats.NewFastlyStatsProvider(cfg.FastlyService, cfg.FastlyAPIKey, ch)
+	providerOptions, err := cfg.ProviderOptions()
 	if err != nil {
-		ll.Fatal(err)
+		ll.Fatalf("Bad provider configuration: %v", err)
 	}
 
-	var consumers []chan *fastlystats.FastlyMeanStats
-	if useStackdriver {
-		consumers = append(consumers, make(chan *fastlystats.FastlyMeanStats, 1024))
+	ch := make(chan *fastlystats.FastlyMeanStats)
+
+	var providers []statsProvider
+	if cfg.FastlyDiscoverServices {
+		discoverer, err := fastlystats.NewServiceDiscoverer(cfg.FastlyAPIKey, cfg.FastlyDiscoveryInterval, providerOptions, ch)
+		if err != nil {
+			ll.Fatal(err)
+		}
+		providers = append(providers, discoverer)
+	} else {
+		for _, service := range services {
+			provider, err := fastlystats.NewFastlyStatsProvider(service, cfg.FastlyAPIKey, providerOptions, ch)
+			if err != nil {
+				ll.Fatal(err)
+			}
+			providers = append(providers, provider)
+		}
 	}
-	if useNewRelic {
-		consumers = append(consumers, make(chan *fastlystats.FastlyMeanStats, 1024))
+
+	sanitizePolicy, err := fastlystats.ParseSanitizePolicy(cfg.SanitizePolicy)
+	if err != nil {
+		ll.Fatalf("Bad SANITIZE_POLICY: %v", err)
 	}
+	sanitized := make(chan *fastlystats.FastlyMeanStats)
+	sanitizer := fastlystats.NewSanitizer(sanitizePolicy, ch, sanitized)
 
-	go multiplexChannel(ctx, ch, consumers)
+	fanout := fastlystats.NewFanout(sanitized)
 
-	wg := sync.WaitGroup{}
-	wg.Add(len(consumers) + 1) // + 1 for the Fastly provider
+	exporters, sinks, err := fastlystats.NewSinks(cfg, fanout)
+	if err != nil {
+		ll.Fatal(err)
+	}
 
-	if len(consumers) == 0 {
-		ll.Fatal("No consumers enabled. Add appropriate keys to env to enable consumers")
+	if len(exporters) == 0 {
+		ll.Fatalf("No sinks enabled (available: %s). Set -project or env GOOGLE_CLOUD_PROJECT for Stackdriver, "+
+			"env NEWRELIC_INSERT_KEY for New Relic, env PROMETHEUS_LISTEN_ADDR for Prometheus or env FILE_SINK_PATH for a file",
+			strings.Join(fastlystats.Exporters(), ", "))
 	}
+	ll.Infof("Enabled sinks: %s", strings.Join(sinks, ", "))
 
-	go func() {
-		defer wg.Done()
-		defer cancel()
-		provider.Run(ctx)
-	}()
+	go sanitizer.Run(ctx)
+	go fanout.Run(ctx)
 
-	if useStackdriver {
+	if cfg.HealthListenAddr != "" {
+		reporters := make([]fastlystats.StatusReporter, 0, len(providers))
+		for _, provider := range providers {
+			reporters = append(reporters, provider)
+		}
+		healthServer := fastlystats.NewHealthServer(cfg.HealthListenAddr, reporters, fanout, sanitizer)
 		go func() {
-			defer wg.Done()
+			// The health server only returns early when it fails
 			defer cancel()
-			consumer, err := fastlystats.NewStackdriverExporter(googleCloudProject, cfg.FastlyService, consumers[0])
-			if err != nil {
-				ll.Fatal(err)
-			}
-			consumer.Run(ctx)
+			healthServer.Run(ctx)
 		}()
 	}
 
-	if useNewRelic {
-		go func() {
+	wg := sync.WaitGroup{}
+	wg.Add(len(exporters) + len(providers))
+
+	// A provider stops by itself when Fastly rejects the API key. The others
+	// keep running, and the health endpoint reports it, until none is left.
+	var running atomic.Int32
+	running.Store(int32(len(providers)))
+	for _, provider := range providers {
+		go func(provider statsProvider) {
 			defer wg.Done()
-			defer cancel()
-			consumer, err := fastlystats.NewNewRelicExporter(cfg.NewRelicInsertKey, consumers[1])
-			if err != nil {
-				ll.Fatal(err)
+			provider.Run(ctx)
+			if running.Add(-1) == 0 && ctx.Err() == nil {
+				ll.Error("All providers have stopped, exiting")
+				cancel()
 			}
-			consumer.Run(ctx)
-		}()
+		}(provider)
+	}
+
+	for _, exporter := range exporters {
+		go func(exporter fastlystats.Exporter) {
+			defer wg.Done()
+			defer cancel()
+			exporter.Run(ctx)
+		}(exporter)
 	}
 
 	wg.Wait()
@@ -1,7 +1,192 @@
 package fastlystats
 
+import (
+	"fmt"
+	"time"
+)
+
 type Config struct {
-	FastlyAPIKey      string `env:"FASTLY_API_KEY"`
-	FastlyService     string `env:"FASTLY_SERVICE"`
-	NewRelicInsertKey string `env:"NEWRELIC_INSERT_KEY"`
+	FastlyAPIKey            string            `env:"FASTLY_API_KEY"`
+	FastlyService           string            `env:"FASTLY_SERVICE"`
+	FastlyServices          []string          `env:"FASTLY_SERVICES"`
+	FastlyDiscoverServices  bool              `env:"FASTLY_DISCOVER_SERVICES"`
+	FastlyDiscoveryInterval time.Duration     `env:"FASTLY_DISCOVERY_INTERVAL,default=5m"`
+	FastlyPerPOP            bool              `env:"FASTLY_PER_POP"`
+	FastlyPollInterval      time.Duration     `env:"FASTLY_POLL_INTERVAL,default=15s"`
+	FastlyRawSamples        bool              `env:"FASTLY_RAW_SAMPLES"`
+	FastlyStreaming         bool              `env:"FASTLY_STREAMING"`
+	FastlyAllFields         bool              `env:"FASTLY_ALL_FIELDS"`
+	CheckpointFile          string            `env:"CHECKPOINT_FILE"`
+	FastlyBackfill          bool              `env:"FASTLY_BACKFILL"`
+	FieldAggregations       map[string]string `env:"FIELD_AGGREGATIONS"`
+	DerivedMetricsFile      string            `env:"DERIVED_METRICS_FILE"`
+	FieldInclude            []string          `env:"FIELD_INCLUDE"`
+	FieldExclude            []string          `env:"FIELD_EXCLUDE"`
+	SinkFieldInclude        []string          `env:"SINK_FIELD_INCLUDE"`
+	SinkFieldExclude        []string          `env:"SINK_FIELD_EXCLUDE"`
+	ExportRatesAsDouble     bool              `env:"EXPORT_RATES_AS_DOUBLE"`
+	DistributionFields      []string          `env:"DISTRIBUTION_FIELDS"`
+	GoogleCloudProject      string            `env:"GOOGLE_CLOUD_PROJECT"`
+	StackdriverCounterKind  string            `env:"STACKDRIVER_COUNTER_KIND,default=gauge"`
+	NewRelicInsertKey       string            `env:"NEWRELIC_INSERT_KEY"`
+	NewRelicRetryDeadline   time.Duration     `env:"NEWRELIC_RETRY_DEADLINE,default=2m"`
+	PrometheusListenAddr    string            `env:"PROMETHEUS_LISTEN_ADDR"`
+	FileSinkPath            string            `env:"FILE_SINK_PATH"`
+	HealthListenAddr        string            `env:"HEALTH_LISTEN_ADDR"`
+	SanitizePolicy          string            `env:"SANITIZE_POLICY,default=drop"`
+
+	SinkQueueSize            int               `env:"SINK_QUEUE_SIZE,default=1024"`
+	SinkBackpressurePolicy   string            `env:"SINK_BACKPRESSURE_POLICY,default=drop_oldest"`
+	SinkBackpressurePolicies map[string]string `env:"SINK_BACKPRESSURE_POLICIES"`
+}
+
+// BackpressurePolicy returns the policy for the named sink, as set in
+// SINK_BACKPRESSURE_POLICIES (e.g. "newrelic:drop_newest,stackdriver:block"),
+// falling back to SINK_BACKPRESSURE_POLICY.
+func (c *Config) BackpressurePolicy(sink string) (BackpressurePolicy, error) {
+	for name := range c.SinkBackpressurePolicies {
+		if err := checkSink(name); err != nil {
+			return "", fmt.Errorf("SINK_BACKPRESSURE_POLICIES: %w", err)
+		}
+	}
+
+	if p, ok := c.SinkBackpressurePolicies[sink]; ok {
+		return ParseBackpressurePolicy(p)
+	}
+
+	return ParseBackpressurePolicy(c.SinkBackpressurePolicy)
+}
+
+// ProviderOptions returns the options every FastlyStatsProvider is created with.
+func (c *Config) ProviderOptions() (ProviderOptions, error) {
+	aggregations := make(map[string]Aggregation, len(c.FieldAggregations))
+	for name, v := range c.FieldAggregations {
+		a, err := ParseAggregation(v)
+		if err != nil {
+			return ProviderOptions{}, fmt.Errorf("FIELD_AGGREGATIONS: field %s: %w", name, err)
+		}
+		aggregations[name] = a
+	}
+
+	opts := ProviderOptions{
+		PollInterval:       c.FastlyPollInterval,
+		PerPOP:             c.FastlyPerPOP,
+		Aggregations:       aggregations,
+		DistributionFields: c.DistributionFields,
+		Raw:                c.FastlyRawSamples,
+		Backfill:           c.FastlyBackfill,
+		Streaming:          c.FastlyStreaming,
+		AllFields:          c.FastlyAllFields,
+	}
+
+	derived, err := c.DerivedMetrics()
+	if err != nil {
+		return ProviderOptions{}, err
+	}
+	opts.DerivedMetrics = derived
+
+	if c.CheckpointFile != "" {
+		store, err := NewFileCheckpointStore(c.CheckpointFile)
+		if err != nil {
+			return ProviderOptions{}, fmt.Errorf("CHECKPOINT_FILE: %w", err)
+		}
+		opts.Checkpoints = store
+	}
+
+	return opts, nil
+}
+
+// DerivedMetrics returns the derived metrics defined in DERIVED_METRICS_FILE,
+// if set.
+func (c *Config) DerivedMetrics() ([]*DerivedMetric, error) {
+	if c.DerivedMetricsFile == "" {
+		return nil, nil
+	}
+
+	metrics, err := LoadDerivedMetrics(c.DerivedMetricsFile, c.FastlyAllFields)
+	if err != nil {
+		return nil, fmt.Errorf("DERIVED_METRICS_FILE: %w", err)
+	}
+
+	return metrics, nil
+}
+
+// AddDerivedMetrics adds the derived metrics defined in DERIVED_METRICS_FILE
+// to the catalog, so that they are exported with their unit and description.
+func (c *Config) AddDerivedMetrics() error {
+	metrics, err := c.DerivedMetrics()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range metrics {
+		if err := AddCatalogEntry(m.CatalogEntry()); err != nil {
+			return fmt.Errorf("DERIVED_METRICS_FILE: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ExportOptions returns the options the exporter of the named sink is created
+// with.
+func (c *Config) ExportOptions(sink string) (ExportOptions, error) {
+	counterKind, err := ParseCounterKind(c.StackdriverCounterKind)
+	if err != nil {
+		return ExportOptions{}, fmt.Errorf("STACKDRIVER_COUNTER_KIND: %w", err)
+	}
+
+	fields, err := c.FieldFilters(sink)
+	if err != nil {
+		return ExportOptions{}, err
+	}
+
+	return ExportOptions{
+		RatesAsDouble:      c.ExportRatesAsDouble,
+		CounterKind:        counterKind,
+		DistributionFields: c.DistributionFields,
+		Fields:             fields,
+	}, nil
+}
+
+// FieldFilters returns the filters of the named sink: the global one from
+// FIELD_INCLUDE and FIELD_EXCLUDE, and the one of the sink from
+// SINK_FIELD_INCLUDE and SINK_FIELD_EXCLUDE (e.g. "newrelic:*_bytes").
+func (c *Config) FieldFilters(sink string) ([]FieldFilter, error) {
+	global, err := NewFieldFilter(c.FieldInclude, c.FieldExclude)
+	if err != nil {
+		return nil, fmt.Errorf("FIELD_INCLUDE or FIELD_EXCLUDE: %w", err)
+	}
+
+	include, err := sinkPatterns(c.SinkFieldInclude, sink)
+	if err != nil {
+		return nil, fmt.Errorf("SINK_FIELD_INCLUDE: %w", err)
+	}
+	exclude, err := sinkPatterns(c.SinkFieldExclude, sink)
+	if err != nil {
+		return nil, fmt.Errorf("SINK_FIELD_EXCLUDE: %w", err)
+	}
+	own, err := NewFieldFilter(include, exclude)
+	if err != nil {
+		return nil, fmt.Errorf("SINK_FIELD_INCLUDE or SINK_FIELD_EXCLUDE: %w", err)
+	}
+
+	return []FieldFilter{global, own}, nil
+}
+
+// Services returns the Fastly services to monitor. FASTLY_SERVICE and the
+// comma separated FASTLY_SERVICES are combined, with duplicates and empty
+// entries removed.
+func (c *Config) Services() []string {
+	seen := map[string]bool{}
+	var services []string
+	for _, s := range append([]string{c.FastlyService}, c.FastlyServices...) {
+		if s == "" || seen[s] {
+			continue
+		}
+		seen[s] = true
+		services = append(services, s)
+	}
+
+	return services
 }
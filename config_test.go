@@ -0,0 +1,37 @@
+package fastlystats
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFieldFiltersUnknownSink(t *testing.T) {
+	cfg := &Config{SinkFieldExclude: []string{"newrelic:*_time", "newrelc:*_bytes"}}
+	if _, err := cfg.FieldFilters("stackdriver"); err == nil || !strings.Contains(err.Error(), `unknown sink "newrelc"`) {
+		t.Errorf("FieldFilters() error = %v, want unknown sink newrelc", err)
+	}
+
+	cfg = &Config{SinkFieldExclude: []string{"newrelic:*_time"}}
+	filters, err := cfg.FieldFilters("newrelic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filters[1].Match("hits_time") || !filters[1].Match("hits") {
+		t.Errorf("FieldFilters() = %v, want hits_time excluded", filters)
+	}
+}
+
+func TestBackpressurePolicyUnknownSink(t *testing.T) {
+	cfg := &Config{
+		SinkBackpressurePolicy:   "drop_oldest",
+		SinkBackpressurePolicies: map[string]string{"stackdrivr": "block"},
+	}
+	if _, err := cfg.BackpressurePolicy("stackdriver"); err == nil || !strings.Contains(err.Error(), `unknown sink "stackdrivr"`) {
+		t.Errorf("BackpressurePolicy() error = %v, want unknown sink stackdrivr", err)
+	}
+
+	cfg.SinkBackpressurePolicies = map[string]string{"stackdriver": "block"}
+	if p, err := cfg.BackpressurePolicy("stackdriver"); err != nil || p != Block {
+		t.Errorf("BackpressurePolicy() = %v, %v, want %v", p, err, Block)
+	}
+}
@@ -0,0 +1,332 @@
+package fastlystats
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+// DerivedMetric is a metric computed from other fields with an arithmetic
+// expression, e.g. "errors / requests". It is exported like any field.
+type DerivedMetric struct {
+	Name        string     `json:"name"`
+	Expression  string     `json:"expression"`
+	Unit        string     `json:"unit"`
+	Description string     `json:"description"`
+	Kind        MetricKind `json:"kind"`
+
+	expr expr
+}
+
+var derivedMetricName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// LoadDerivedMetrics reads derived metrics from a JSON file holding a list of
+// DerivedMetric. Expressions may only use known fields and the metrics
+// defined before them, unless allFields is set, as fields Fastly adds are
+// then read as well.
+func LoadDerivedMetrics(path string, allFields bool) ([]*DerivedMetric, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var metrics []*DerivedMetric
+	if err := json.Unmarshal(b, &metrics); err != nil {
+		return nil, err
+	}
+
+	return parseDerivedMetrics(metrics, allFields)
+}
+
+func parseDerivedMetrics(metrics []*DerivedMetric, allFields bool) ([]*DerivedMetric, error) {
+	var err error
+
+	seen := map[string]bool{}
+	for _, m := range metrics {
+		if !derivedMetricName.MatchString(m.Name) {
+			return nil, fmt.Errorf("bad derived metric name %q, must be lower case letters, digits and underscores", m.Name)
+		}
+		if seen[m.Name] {
+			return nil, fmt.Errorf("derived metric %s defined twice", m.Name)
+		}
+		seen[m.Name] = true
+
+		switch m.Kind {
+		case "":
+			m.Kind = KindGauge
+		case KindGauge, KindCounter:
+		default:
+			return nil, fmt.Errorf("derived metric %s: invalid kind %q, must be %s or %s", m.Name, m.Kind, KindGauge, KindCounter)
+		}
+
+		m.expr, err = parseExpression(m.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("derived metric %s: %w", m.Name, err)
+		}
+
+		if allFields {
+			continue
+		}
+		for _, name := range fieldsOf(m.expr) {
+			_, native := statFields[name]
+			_, cataloged := catalogIndex[name]
+			if !native && !cataloged && (!seen[name] || name == m.Name) {
+				return nil, fmt.Errorf("derived metric %s: unknown field %s, set FASTLY_ALL_FIELDS to use fields unknown to the exporter", m.Name, name)
+			}
+		}
+	}
+
+	return metrics, nil
+}
+
+// CatalogEntry returns the catalog entry of the metric.
+func (m *DerivedMetric) CatalogEntry() CatalogEntry {
+	return CatalogEntry{
+		Name:        m.Name,
+		DisplayName: m.Name,
+		Unit:        m.Unit,
+		Description: m.Description,
+		Kind:        m.Kind,
+		Aggregation: AggregateMean,
+		Enabled:     true,
+	}
+}
+
+// Eval computes the metric from values. Division by zero, and any other
+// result that is not a finite number, gives 0.
+func (m *DerivedMetric) Eval(values StatValues) float64 {
+	v := m.expr.eval(values)
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0
+	}
+
+	return v
+}
+
+// derive adds the derived metrics to the values and totals of s, overall
+// and per POP. They are evaluated in order, so a metric can use those before
+// it.
+func (s *FastlyMeanStats) derive(metrics []*DerivedMetric) {
+	if len(metrics) == 0 {
+		return
+	}
+
+	deriveValues(metrics, s.Values, s.Totals)
+	for pop, values := range s.DatacenterValues {
+		deriveValues(metrics, values, s.DatacenterTotals[pop])
+	}
+}
+
+// deriveValues adds the derived metrics to values and totals. Gauges are
+// computed from the totals of the interval, as the fields they combine may
+// be aggregated differently, e.g. miss_time summed and miss averaged. The
+// result is the value of the whole interval, so it is stored in both.
+// Counters are computed from values and totals separately.
+func deriveValues(metrics []*DerivedMetric, values, totals StatValues) {
+	if values == nil || totals == nil {
+		return
+	}
+
+	for _, m := range metrics {
+		if m.Kind == KindCounter {
+			values[m.Name] = m.Eval(values)
+			totals[m.Name] = m.Eval(totals)
+			continue
+		}
+
+		v := m.Eval(totals)
+		values[m.Name] = v
+		totals[m.Name] = v
+	}
+}
+
+// expr is a parsed expression. Fields missing from the values count as 0.
+type expr interface {
+	eval(values StatValues) float64
+}
+
+// fieldsOf returns the field names e uses.
+func fieldsOf(e expr) []string {
+	switch e := e.(type) {
+	case fieldExpr:
+		return []string{string(e)}
+	case negExpr:
+		return fieldsOf(e.x)
+	case binaryExpr:
+		return append(fieldsOf(e.x), fieldsOf(e.y)...)
+	}
+
+	return nil
+}
+
+type numberExpr float64
+
+func (e numberExpr) eval(StatValues) float64 {
+	return float64(e)
+}
+
+type fieldExpr string
+
+func (e fieldExpr) eval(values StatValues) float64 {
+	return values[string(e)]
+}
+
+type negExpr struct {
+	x expr
+}
+
+func (e negExpr) eval(values StatValues) float64 {
+	return -e.x.eval(values)
+}
+
+type binaryExpr struct {
+	op   byte
+	x, y expr
+}
+
+func (e binaryExpr) eval(values StatValues) float64 {
+	x, y := e.x.eval(values), e.y.eval(values)
+	switch e.op {
+	case '+':
+		return x + y
+	case '-':
+		return x - y
+	case '*':
+		return x * y
+	default:
+		if y == 0 {
+			return 0
+		}
+		return x / y
+	}
+}
+
+// parseExpression parses an expression of numbers and field names combined
+// with +, -, *, / and parentheses, with the usual precedence.
+func parseExpression(s string) (expr, error) {
+	p := &exprParser{s: s}
+	e, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.s) {
+		return nil, fmt.Errorf("unexpected %q at %d in %q", p.s[p.pos], p.pos, s)
+	}
+
+	return e, nil
+}
+
+type exprParser struct {
+	s   string
+	pos int
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// peek returns the next character, or 0 at the end.
+func (p *exprParser) peek() byte {
+	if p.skipSpace(); p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+
+	return 0
+}
+
+// sum parses terms separated by + and -.
+func (p *exprParser) sum() (expr, error) {
+	x, err := p.product()
+	if err != nil {
+		return nil, err
+	}
+
+	for op := p.peek(); op == '+' || op == '-'; op = p.peek() {
+		p.pos++
+		y, err := p.product()
+		if err != nil {
+			return nil, err
+		}
+		x = binaryExpr{op: op, x: x, y: y}
+	}
+
+	return x, nil
+}
+
+// product parses factors separated by * and /.
+func (p *exprParser) product() (expr, error) {
+	x, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+
+	for op := p.peek(); op == '*' || op == '/'; op = p.peek() {
+		p.pos++
+		y, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		x = binaryExpr{op: op, x: x, y: y}
+	}
+
+	return x, nil
+}
+
+// factor parses a number, a field name, a negation or an expression in
+// parentheses.
+func (p *exprParser) factor() (expr, error) {
+	c := p.peek()
+	switch {
+	case c == 0:
+		return nil, fmt.Errorf("unexpected end of %q", p.s)
+
+	case c == '-':
+		p.pos++
+		x, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		return negExpr{x: x}, nil
+
+	case c == '(':
+		p.pos++
+		x, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("missing ) at %d in %q", p.pos, p.s)
+		}
+		p.pos++
+		return x, nil
+
+	case c >= '0' && c <= '9' || c == '.':
+		start := p.pos
+		for p.pos < len(p.s) && (p.s[p.pos] >= '0' && p.s[p.pos] <= '9' || p.s[p.pos] == '.') {
+			p.pos++
+		}
+		v, err := strconv.ParseFloat(p.s[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad number %q at %d in %q", p.s[start:p.pos], start, p.s)
+		}
+		return numberExpr(v), nil
+
+	case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_':
+		start := p.pos
+		for p.pos < len(p.s) && isIdentChar(p.s[p.pos]) {
+			p.pos++
+		}
+		return fieldExpr(p.s[start:p.pos]), nil
+	}
+
+	return nil, fmt.Errorf("unexpected %q at %d in %q", c, p.pos, p.s)
+}
+
+func isIdentChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_'
+}
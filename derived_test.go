@@ -0,0 +1,170 @@
+package fastlystats
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDeriveGaugeFromTotals(t *testing.T) {
+	e, err := parseExpression("miss_time / miss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &DerivedMetric{Name: "avg_miss_latency", Kind: KindGauge, expr: e}
+
+	// 15 seconds with 2 misses taking 0.1s each per second: miss_time is
+	// summed and miss averaged by default.
+	list := make([]StatValues, 15)
+	for i := range list {
+		list[i] = StatValues{"miss": 2, "miss_time": 0.2}
+	}
+	_, values, totals := aggregateOf(list, 15, nil)
+	s := &FastlyMeanStats{Values: values, Totals: totals}
+	s.derive([]*DerivedMetric{m})
+
+	for what, got := range map[string]float64{
+		"value": s.Values["avg_miss_latency"],
+		"total": s.Totals["avg_miss_latency"],
+	} {
+		if math.Abs(got-0.1) > 1e-9 {
+			t.Errorf("%s: got %g, want 0.1", what, got)
+		}
+	}
+}
+
+func TestDeriveCounter(t *testing.T) {
+	e, err := parseExpression("requests - errors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &DerivedMetric{Name: "successes", Kind: KindCounter, expr: e}
+
+	s := &FastlyMeanStats{
+		Values: StatValues{"requests": 10, "errors": 1},
+		Totals: StatValues{"requests": 150, "errors": 15},
+	}
+	s.derive([]*DerivedMetric{m})
+
+	if got := s.Values["successes"]; got != 9 {
+		t.Errorf("value: got %g, want 9", got)
+	}
+	if got := s.Totals["successes"]; got != 135 {
+		t.Errorf("total: got %g, want 135", got)
+	}
+}
+
+func TestParseDerivedMetricsFields(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		expression []string
+		allFields  bool
+		wantErr    bool
+	}{
+		{"known fields", []string{"errors / requests"}, false, false},
+		{"typo", []string{"errros / requests"}, false, true},
+		{"typo with all fields", []string{"errros / requests"}, true, false},
+		{"earlier derived metric", []string{"errors / requests", "1 - m0"}, false, false},
+		{"later derived metric", []string{"m1 * 2", "errors / requests"}, false, true},
+		{"itself", []string{"m0 + 1"}, false, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var metrics []*DerivedMetric
+			for i, e := range tt.expression {
+				metrics = append(metrics, &DerivedMetric{Name: "m" + string(rune('0'+i)), Expression: e})
+			}
+
+			_, err := parseDerivedMetrics(metrics, tt.allFields)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseExpression(t *testing.T) {
+	values := StatValues{"a": 2, "b": 3, "c": 4}
+
+	for _, tt := range []struct {
+		expression string
+		want       float64
+	}{
+		{"a + b * c", 14},
+		{"(a + b) * c", 20},
+		{"a * b + c", 10},
+		{"c - b - a", -1},
+		{"c / a / a", 1},
+		{"c - (b - a)", 3},
+		{"-a", -2},
+		{"-a * b", -6},
+		{"a - -b", 5},
+		{"-(a + b)", -5},
+		{"--a", 2},
+		{"1 - a / c", 0.5},
+		{"((a))", 2},
+		{" a+b ", 5},
+		{"1.5 * a", 3},
+		{".5", 0.5},
+		{"a / 0", 0},
+		{"a / (b - b)", 0},
+		{"unknown", 0},
+	} {
+		t.Run(tt.expression, func(t *testing.T) {
+			e, err := parseExpression(tt.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := &DerivedMetric{expr: e}
+			if got := m.Eval(values); got != tt.want {
+				t.Errorf("got %g, want %g", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExpressionErrors(t *testing.T) {
+	for _, tt := range []struct {
+		expression string
+		want       string
+	}{
+		{"", `unexpected end of ""`},
+		{"a +", `unexpected end of "a +"`},
+		{"(a", `missing ) at 2 in "(a"`},
+		{"(a + b", `missing ) at 6 in "(a + b"`},
+		{"a b", `unexpected 'b' at 2 in "a b"`},
+		{"a + )", `unexpected ')' at 4 in "a + )"`},
+		{"a % b", `unexpected '%' at 2 in "a % b"`},
+		{"a + 1.2.3", `bad number "1.2.3" at 4 in "a + 1.2.3"`},
+		{"a * * b", `unexpected '*' at 4 in "a * * b"`},
+	} {
+		t.Run(tt.expression, func(t *testing.T) {
+			_, err := parseExpression(tt.expression)
+			if err == nil {
+				t.Fatalf("got no error, want %s", tt.want)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("got error %q, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddCatalogEntryReserved(t *testing.T) {
+	for _, name := range []string{
+		"requests",
+		"hit_ratio",
+		"data_lag",
+		"sample_count",
+		"missing_seconds",
+		"miss_histogram",
+		"miss_latency_p50",
+		"miss_latency_p99",
+		"miss_latency",
+		"miss_latency_ms",
+		"data_lag_seconds",
+		"errors_distribution",
+	} {
+		if err := AddCatalogEntry(CatalogEntry{Name: name}); err == nil {
+			t.Errorf("%s: got no error", name)
+		}
+	}
+}
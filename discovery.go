@@ -0,0 +1,160 @@
+package fastlystats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/fastly/go-fastly/v3/fastly"
+	"go.uber.org/zap"
+)
+
+// ServiceDiscoverer lists every service visible to the Fastly API token and
+// keeps a FastlyStatsProvider running for each of them. The list is refreshed
+// periodically so new services are picked up and deleted ones are stopped.
+type ServiceDiscoverer struct {
+	fastlyClient *fastly.Client
+	apiKey       string
+	interval     time.Duration
+	opts         ProviderOptions
+	ch           chan<- *FastlyMeanStats
+
+	mu        sync.Mutex
+	providers map[string]*discoveredProvider
+	// status is set once Fastly has rejected the API key
+	status *ProviderStatus
+	wg     sync.WaitGroup
+}
+
+// discoveryService is the service the status of the discovery itself is
+// reported for.
+const discoveryService = "discovery"
+
+type discoveredProvider struct {
+	provider *FastlyStatsProvider
+	cancel   context.CancelFunc
+}
+
+func NewServiceDiscoverer(apiKey string, interval time.Duration, opts ProviderOptions, ch chan<- *FastlyMeanStats) (*ServiceDiscoverer, error) {
+	fastlyClient, err := fastly.NewClient(apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ServiceDiscoverer{
+		fastlyClient: fastlyClient,
+		apiKey:       apiKey,
+		interval:     interval,
+		opts:         opts,
+		ch:           ch,
+		providers:    map[string]*discoveredProvider{},
+	}, nil
+}
+
+func (d *ServiceDiscoverer) Run(ctx context.Context) {
+	ll := zap.S()
+	ll.Infof("starting fastly service discovery, refreshing every %v", d.interval)
+
+	defer d.wg.Wait()
+
+	for {
+		err := d.sync(ctx)
+		if errors.Is(err, ErrUnauthorized) {
+			// The providers find out by themselves, and stop
+			d.mu.Lock()
+			d.status = &ProviderStatus{Service: discoveryService, State: StateUnauthorized, Since: time.Now(), Error: err.Error()}
+			d.mu.Unlock()
+			ll.Errorf("fastly rejected the API key, stopping service discovery: %v", err)
+			return
+		}
+		if err != nil {
+			ll.Warnf("failed to list services, keeping the current set: %v", err)
+		}
+
+		select {
+		case <-time.After(d.interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// sync starts providers for services that have appeared and stops those
+// of services that are gone since the last listing.
+func (d *ServiceDiscoverer) sync(ctx context.Context) error {
+	ll := zap.S()
+
+	services, err := d.fastlyClient.ListServices(&fastly.ListServicesInput{})
+	if err != nil {
+		var httpErr *fastly.HTTPError
+		if errors.As(err, &httpErr) && (httpErr.StatusCode == http.StatusUnauthorized || httpErr.StatusCode == http.StatusForbidden) {
+			return fmt.Errorf("%w: %v", ErrUnauthorized, err)
+		}
+		return err
+	}
+
+	current := map[string]bool{}
+	for _, service := range services {
+		if service.DeletedAt != nil {
+			continue
+		}
+		current[service.ID] = true
+
+		if _, ok := d.providers[service.ID]; ok {
+			continue
+		}
+
+		provider, err := NewFastlyStatsProvider(service.ID, d.apiKey, d.opts, d.ch)
+		if err != nil {
+			ll.Warnf("failed to create provider for service %s (%s): %v", service.ID, service.Name, err)
+			continue
+		}
+
+		ll.Infof("discovered service %s (%s)", service.ID, service.Name)
+
+		pctx, cancel := context.WithCancel(ctx)
+		d.mu.Lock()
+		d.providers[service.ID] = &discoveredProvider{provider: provider, cancel: cancel}
+		d.mu.Unlock()
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			provider.Run(pctx)
+		}()
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for id, p := range d.providers {
+		if current[id] {
+			continue
+		}
+
+		ll.Infof("service %s is gone, stopping its provider", id)
+		p.cancel()
+		delete(d.providers, id)
+	}
+
+	return nil
+}
+
+// Status implements StatusReporter, for every discovered service and, once
+// Fastly has rejected the API key, for the discovery itself.
+func (d *ServiceDiscoverer) Status() []ProviderStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result := make([]ProviderStatus, 0, len(d.providers)+1)
+	for _, p := range d.providers {
+		result = append(result, p.provider.Status()...)
+	}
+	if d.status != nil {
+		result = append(result, *d.status)
+	}
+
+	return result
+}
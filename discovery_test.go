@@ -0,0 +1,46 @@
+package fastlystats
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fastly/go-fastly/v3/fastly"
+)
+
+func TestServiceDiscovererUnauthorized(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"msg":"Provided credentials are missing or invalid"}`))
+	}))
+	defer server.Close()
+
+	client, err := fastly.NewClientForEndpoint("key", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &ServiceDiscoverer{
+		fastlyClient: client,
+		interval:     time.Hour,
+		providers:    map[string]*discoveredProvider{},
+	}
+
+	done := make(chan struct{})
+	go func() {
+		d.Run(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("discovery did not stop when the API key was rejected")
+	}
+
+	status := d.Status()
+	if len(status) != 1 || status[0].Service != discoveryService || status[0].State != StateUnauthorized {
+		t.Errorf("Status() = %+v, want the discovery unauthorized", status)
+	}
+}
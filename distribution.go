@@ -0,0 +1,97 @@
+package fastlystats
+
+import (
+	"math"
+	"sort"
+)
+
+// Distribution describes the per-second values of a field within one
+// interval, so that short bursts are not hidden by the aggregate.
+type Distribution struct {
+	Count  uint64
+	Sum    float64
+	Mean   float64
+	Min    float64
+	Max    float64
+	StdDev float64
+
+	// SumOfSquaredDeviation is the sum of the squared differences from Mean.
+	SumOfSquaredDeviation float64
+
+	P50 float64
+	P90 float64
+	P95 float64
+	P99 float64
+
+	// Samples are the per-second values, sorted ascending.
+	Samples []float64
+}
+
+// distributionsOf computes the distribution of the per-second values of the
+// given fields. A field missing from a sample counts as zero for that
+// second, fields missing from every sample are ignored.
+func distributionsOf(list []StatValues, fields []string) map[string]*Distribution {
+	if len(fields) == 0 || len(list) == 0 {
+		return nil
+	}
+
+	result := make(map[string]*Distribution, len(fields))
+	for _, name := range fields {
+		var found bool
+		samples := make([]float64, 0, len(list))
+		for _, sample := range list {
+			v, ok := sample[name]
+			found = found || ok
+			samples = append(samples, v)
+		}
+		if !found {
+			continue
+		}
+
+		result[name] = newDistribution(samples)
+	}
+
+	return result
+}
+
+func newDistribution(samples []float64) *Distribution {
+	sort.Float64s(samples)
+
+	d := &Distribution{
+		Count:   uint64(len(samples)),
+		Min:     samples[0],
+		Max:     samples[len(samples)-1],
+		Samples: samples,
+	}
+
+	for _, v := range samples {
+		d.Sum += v
+	}
+	d.Mean = d.Sum / float64(d.Count)
+
+	for _, v := range samples {
+		d.SumOfSquaredDeviation += (v - d.Mean) * (v - d.Mean)
+	}
+	d.StdDev = math.Sqrt(d.SumOfSquaredDeviation / float64(d.Count))
+
+	d.P50 = percentile(samples, 50)
+	d.P90 = percentile(samples, 90)
+	d.P95 = percentile(samples, 95)
+	d.P99 = percentile(samples, 99)
+
+	return d
+}
+
+// percentile returns the p-th percentile of sorted, interpolating linearly
+// between the closest ranks.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
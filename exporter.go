@@ -0,0 +1,200 @@
+package fastlystats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrSinkDisabled is returned by an ExporterFactory when its sink is not
+// configured.
+var ErrSinkDisabled = errors.New("sink disabled")
+
+// Exporter reports FastlyMeanStats to a sink until the context is done or its
+// channel is closed.
+type Exporter interface {
+	Run(ctx context.Context)
+}
+
+// RawAccepter is implemented by exporters that can take one-second raw
+// samples. When the providers publish raw samples, such exporters receive
+// them instead of the aggregates.
+type RawAccepter interface {
+	AcceptsRaw() bool
+}
+
+// AcceptsRaw reports whether e can take raw samples.
+func AcceptsRaw(e Exporter) bool {
+	r, ok := e.(RawAccepter)
+	return ok && r.AcceptsRaw()
+}
+
+// ExportOptions tune how the exporters turn stats into sink values.
+type ExportOptions struct {
+	// RatesAsDouble exports integer fields as doubles, from the unrounded
+	// FastlyMeanStats.Values, so that rates below 1/s are not rounded away.
+	RatesAsDouble bool
+
+	// CounterKind is how counter-like fields (requests, hits, errors, bytes,
+	// times spent) are exported by sinks that distinguish gauges from
+	// counters.
+	CounterKind CounterKind
+
+	// DistributionFields are the fields the providers compute distributions
+	// for. Exporters export whatever distributions they receive, this is
+	// used to set up metric definitions ahead of time.
+	DistributionFields []string
+
+	// Fields are the filters a field has to pass, on top of being enabled
+	// in the catalog, to be exported. Distributions are not filtered.
+	Fields []FieldFilter
+}
+
+// CounterKind is the kind of series counter-like fields are exported as.
+type CounterKind string
+
+const (
+	// CounterGauge exports the aggregated per-second value as a gauge.
+	CounterGauge = CounterKind("gauge")
+	// CounterDelta exports the total over each interval.
+	CounterDelta = CounterKind("delta")
+	// CounterCumulative exports the running total since the exporter started.
+	CounterCumulative = CounterKind("cumulative")
+)
+
+func ParseCounterKind(s string) (CounterKind, error) {
+	switch k := CounterKind(s); k {
+	case CounterGauge, CounterDelta, CounterCumulative:
+		return k, nil
+	}
+
+	return "", fmt.Errorf("invalid counter kind %q, must be one of %s, %s or %s", s, CounterGauge, CounterDelta, CounterCumulative)
+}
+
+// IntervalLimiter is implemented by exporters whose sink does not accept
+// points for the same series more often than MinInterval.
+type IntervalLimiter interface {
+	MinInterval() time.Duration
+}
+
+// CheckPollInterval returns an error if the exporter cannot accept a snapshot
+// every interval.
+func CheckPollInterval(interval time.Duration, name string, e Exporter) error {
+	l, ok := e.(IntervalLimiter)
+	if !ok {
+		return nil
+	}
+
+	if min := l.MinInterval(); interval < min {
+		return fmt.Errorf("poll interval %v is shorter than the %v minimum of sink %s", interval, min, name)
+	}
+
+	return nil
+}
+
+// ExporterFactory creates the Exporter for a sink, reading stats from ch. It
+// returns ErrSinkDisabled if cfg does not enable the sink.
+type ExporterFactory func(cfg *Config, ch <-chan *FastlyMeanStats) (Exporter, error)
+
+var (
+	exportersMu sync.RWMutex
+	exporters   = map[string]ExporterFactory{}
+)
+
+// RegisterExporter makes a sink available by name. It panics if the name is
+// registered twice.
+func RegisterExporter(name string, factory ExporterFactory) {
+	exportersMu.Lock()
+	defer exportersMu.Unlock()
+
+	if factory == nil {
+		panic("fastlystats: RegisterExporter factory is nil")
+	}
+	if _, ok := exporters[name]; ok {
+		panic(fmt.Sprintf("fastlystats: RegisterExporter called twice for sink %q", name))
+	}
+	exporters[name] = factory
+}
+
+// Exporters returns the names of all registered sinks, sorted.
+func Exporters() []string {
+	exportersMu.RLock()
+	defer exportersMu.RUnlock()
+
+	names := make([]string, 0, len(exporters))
+	for name := range exporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// checkSink returns an error unless a sink of that name is registered, so
+// that settings for a misspelled sink are not silently ignored.
+func checkSink(name string) error {
+	exportersMu.RLock()
+	_, ok := exporters[name]
+	exportersMu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("unknown sink %q, must be one of %s", name, strings.Join(Exporters(), ", "))
+	}
+
+	return nil
+}
+
+// NewExporter creates the Exporter of the named sink.
+func NewExporter(name string, cfg *Config, ch <-chan *FastlyMeanStats) (Exporter, error) {
+	exportersMu.RLock()
+	factory, ok := exporters[name]
+	exportersMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown sink %q: %w", name, ErrNotFound)
+	}
+
+	return factory(cfg, ch)
+}
+
+// NewSinks creates the exporter of every enabled sink and adds its queue to
+// fanout. Along with the exporters it returns a description of each sink.
+func NewSinks(cfg *Config, fanout *Fanout) ([]Exporter, []string, error) {
+	var (
+		result []Exporter
+		sinks  []string
+	)
+	for _, name := range Exporters() {
+		policy, err := cfg.BackpressurePolicy(name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("bad backpressure policy for sink %s: %w", name, err)
+		}
+
+		ch := make(chan *FastlyMeanStats, cfg.SinkQueueSize)
+		exporter, err := NewExporter(name, cfg, ch)
+		if errors.Is(err, ErrSinkDisabled) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create %s exporter: %w", name, err)
+		}
+		if err := CheckPollInterval(cfg.FastlyPollInterval, name, exporter); err != nil {
+			return nil, nil, fmt.Errorf("incompatible configuration: %w", err)
+		}
+
+		raw := cfg.FastlyRawSamples && AcceptsRaw(exporter)
+		fanout.AddSink(name, policy, raw, ch)
+		result = append(result, exporter)
+		if raw {
+			sinks = append(sinks, fmt.Sprintf("%s (%s, raw samples)", name, policy))
+		} else {
+			sinks = append(sinks, fmt.Sprintf("%s (%s)", name, policy))
+		}
+	}
+
+	return result, sinks, nil
+}
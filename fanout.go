@@ -0,0 +1,166 @@
+package fastlystats
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// BackpressurePolicy decides what happens to a snapshot when a sink's queue
+// is full.
+type BackpressurePolicy string
+
+const (
+	// DropOldest discards the oldest queued snapshot to make room for the new one.
+	DropOldest = BackpressurePolicy("drop_oldest")
+	// DropNewest discards the new snapshot and keeps the queue as it is.
+	DropNewest = BackpressurePolicy("drop_newest")
+	// Block waits until the sink has room. This stalls delivery to every other
+	// sink, and eventually the providers, for as long as the sink is stuck.
+	Block = BackpressurePolicy("block")
+)
+
+func ParseBackpressurePolicy(s string) (BackpressurePolicy, error) {
+	switch p := BackpressurePolicy(s); p {
+	case DropOldest, DropNewest, Block:
+		return p, nil
+	}
+
+	return "", fmt.Errorf("invalid backpressure policy %q, must be one of %s, %s or %s", s, DropOldest, DropNewest, Block)
+}
+
+// dropWarnInterval is how often at most a sink's drops are logged.
+const dropWarnInterval = time.Minute
+
+type fanoutSink struct {
+	name    string
+	policy  BackpressurePolicy
+	raw     bool
+	ch      chan *FastlyMeanStats
+	dropped atomic.Uint64
+
+	// lastWarned is when drops were last logged, and warned the total at
+	// that time. They are only used by Run.
+	lastWarned time.Time
+	warned     uint64
+}
+
+// Fanout delivers every snapshot read from its input to all sinks. Each sink
+// has its own queue, and unless its policy is Block a full queue never holds
+// up delivery to the others. When the input is closed, so are the queues.
+type Fanout struct {
+	in    <-chan *FastlyMeanStats
+	sinks []*fanoutSink
+}
+
+func NewFanout(in <-chan *FastlyMeanStats) *Fanout {
+	return &Fanout{in: in}
+}
+
+// AddSink registers the queue of a sink. A raw sink receives only raw
+// samples, any other sink only aggregates. Both receive backfilled
+// snapshots. It must be called before Run.
+func (f *Fanout) AddSink(name string, policy BackpressurePolicy, raw bool, ch chan *FastlyMeanStats) {
+	f.sinks = append(f.sinks, &fanoutSink{
+		name:   name,
+		policy: policy,
+		raw:    raw,
+		ch:     ch,
+	})
+}
+
+// Dropped returns the number of snapshots dropped so far, per sink.
+func (f *Fanout) Dropped() map[string]uint64 {
+	dropped := make(map[string]uint64, len(f.sinks))
+	for _, sink := range f.sinks {
+		dropped[sink.name] = sink.dropped.Load()
+	}
+
+	return dropped
+}
+
+func (f *Fanout) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case stats, ok := <-f.in:
+			if !ok {
+				for _, sink := range f.sinks {
+					close(sink.ch)
+				}
+				return
+			}
+			for _, sink := range f.sinks {
+				if stats.Raw != sink.raw && !stats.Backfilled {
+					continue
+				}
+				if err := sink.deliver(ctx, stats); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// deliver queues stats as the policy of the sink says. Backfilled minutes
+// always block, as the backfill pushes them far faster than a sink takes
+// them and nothing would fill the gap of the ones dropped.
+func (s *fanoutSink) deliver(ctx context.Context, stats *FastlyMeanStats) error {
+	select {
+	case s.ch <- stats:
+		return nil
+	default:
+	}
+
+	policy := s.policy
+	if stats.Backfilled {
+		policy = Block
+	}
+
+	switch policy {
+	case Block:
+		select {
+		case s.ch <- stats:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+	case DropOldest:
+		select {
+		case <-s.ch:
+			s.drop()
+		default:
+		}
+
+		select {
+		case s.ch <- stats:
+		default:
+			// The queue was refilled in between, give up on this snapshot
+			s.drop()
+		}
+
+	default:
+		s.drop()
+	}
+
+	return nil
+}
+
+// drop counts a dropped snapshot. A stalled sink drops every snapshot, so
+// the drops are logged at most once per dropWarnInterval.
+func (s *fanoutSink) drop() {
+	n := s.dropped.Add(1)
+
+	now := time.Now()
+	if now.Sub(s.lastWarned) < dropWarnInterval {
+		return
+	}
+	zap.S().Warnf("queue of sink %s is full, dropped %d snapshots since the last warning (%s, %d dropped in total)", s.name, n-s.warned, s.policy, n)
+	s.lastWarned = now
+	s.warned = n
+}
@@ -2,147 +2,679 @@ package fastlystats
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"math"
-	"reflect"
+	"net/http"
+	"sync"
 	"time"
 
 	"github.com/fastly/go-fastly/v3/fastly"
 	"go.uber.org/zap"
 )
 
-// Must not be shorter than the quota for creating time series.
-// See https://cloud.google.com/monitoring/quotas
-// At the time of writing this is 1 point per 10 seconds, so must be more than 10 seconds
+// DefaultPollInterval is used when ProviderOptions.PollInterval is not set.
 //
-// The shorter this is, the higher resolution there will be on datapoints, but
-// also increases resource usage and billing
-const pollInterval = 15 * time.Second
+// The shorter the interval, the higher resolution there will be on datapoints,
+// but it also increases resource usage and billing. Sinks may not accept
+// intervals below a minimum, see IntervalLimiter.
+const DefaultPollInterval = 15 * time.Second
+
+// streamDelay is how long after the end of a window a streaming provider
+// waits for its last seconds before publishing it.
+const streamDelay = 5 * time.Second
 
 type FastlyMeanStats struct {
+	Service string
+
+	// Raw marks a single one-second sample rather than an aggregate over the
+	// poll interval, see ProviderOptions.Raw.
+	Raw bool
+
+	// Backfilled marks a minute recovered from the historical stats. It is
+	// delivered to every sink, raw or not, as no finer samples exist.
+	Backfilled bool
+
 	IntervalStart uint64
 	IntervalEnd   uint64
 	Stats         *fastly.Stats
+
+	// Values holds the same stats as Stats, without integer fields rounded.
+	Values StatValues
+
+	// Totals holds the sum of every field over the interval, regardless of
+	// the configured aggregation.
+	Totals StatValues
+
+	// Distributions holds the distribution of the per-second values of the
+	// fields in ProviderOptions.DistributionFields, keyed by metric name.
+	Distributions map[string]*Distribution
+
+	// SampleCount is the number of seconds with data in the interval, and
+	// MissingSeconds the number of seconds without, between IntervalStart and
+	// IntervalEnd and since the previous snapshot.
+	SampleCount    uint64
+	MissingSeconds uint64
+
+	// Lag is how far IntervalEnd was behind the wall clock when the snapshot
+	// was published.
+	Lag time.Duration
+
+	// MissHistogram is the origin latency histogram of all misses in the
+	// interval, or nil if there were none.
+	MissHistogram *LatencyHistogram
+
+	// Datacenters, DatacenterValues and DatacenterTotals hold the stats per
+	// POP, keyed by POP code. They are only set when the provider runs with
+	// ProviderOptions.PerPOP.
+	Datacenters      map[string]*fastly.Stats
+	DatacenterValues map[string]StatValues
+	DatacenterTotals map[string]StatValues
 }
 
+// ProviderOptions tune what a FastlyStatsProvider computes for each interval.
+type ProviderOptions struct {
+	// PollInterval is how often stats are fetched and aggregated.
+	// Defaults to DefaultPollInterval.
+	PollInterval time.Duration
+
+	// PerPOP also aggregates the stats of every POP (datacenter) individually.
+	PerPOP bool
+
+	// Aggregations overrides how the samples of a field are combined, keyed
+	// by metric name. Fields not listed use DefaultAggregation.
+	Aggregations map[string]Aggregation
+
+	// DistributionFields are the fields for which the distribution of the
+	// per-second values is computed as well.
+	DistributionFields []string
+
+	// Raw also publishes every one-second sample individually, marked as
+	// FastlyMeanStats.Raw, before the aggregate of the interval.
+	Raw bool
+
+	// Checkpoints, if set, persists the realtime timestamp after every
+	// response, and the provider resumes from it when it starts.
+	Checkpoints CheckpointStore
+
+	// Backfill fills the gap since the checkpoint from the historical stats
+	// when it is longer than RealtimeRetention, before polling realtime stats.
+	Backfill bool
+
+	// AllFields reads the stats from the realtime JSON rather than from
+	// fastly.Stats, so that fields Fastly adds are aggregated and exported
+	// without updating go-fastly.
+	AllFields bool
+
+	// Streaming requests new stats as soon as the previous ones arrived,
+	// rather than every PollInterval, and publishes them on wall-clock
+	// aligned PollInterval boundaries.
+	Streaming bool
+
+	// DerivedMetrics are computed from the aggregated values and totals of
+	// every snapshot, and added to them.
+	DerivedMetrics []*DerivedMetric
+}
+
+// ErrUnauthorized is returned when Fastly rejects the API key for a service.
+var ErrUnauthorized = errors.New("unauthorized")
+
 type FastlyStatsProvider struct {
-	fastlyClient *fastly.RTSClient
+	// fastlyClient talks to the realtime endpoint. It is a plain client
+	// rather than an RTSClient, which hides the response headers.
+	fastlyClient *fastly.Client
+	backfiller   *Backfiller
 	service      string
+	opts         ProviderOptions
 	ch           chan<- *FastlyMeanStats
 
 	timestamp uint64
+
+	// lastFetched and lastRecorded are the last second fetched and published,
+	// and repeated the number of consecutive responses whose timestamp did
+	// not advance. Apart from streaming they are the same.
+	lastFetched  uint64
+	lastRecorded uint64
+	repeated     int
+
+	// resumedFrom is the checkpoint the provider started from, until the
+	// first response with data after it has been accounted for.
+	resumedFrom uint64
+
+	// throttle is how long to hold off the next poll because the rate limit
+	// has been used up.
+	throttle time.Duration
+
+	mu     sync.Mutex
+	status ProviderStatus
 }
 
-func NewFastlyStatsProvider(service, apiKey string, ch chan<- *FastlyMeanStats) (*FastlyStatsProvider, error) {
-	fastlyClient, err := fastly.NewRealtimeStatsClientForEndpoint(apiKey, fastly.DefaultRealtimeStatsEndpoint)
+func NewFastlyStatsProvider(service, apiKey string, opts ProviderOptions, ch chan<- *FastlyMeanStats) (*FastlyStatsProvider, error) {
+	fastlyClient, err := fastly.NewClientForEndpoint(apiKey, fastly.DefaultRealtimeStatsEndpoint)
 	if err != nil {
 		return nil, err
 	}
 
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultPollInterval
+	}
+
+	var backfiller *Backfiller
+	if opts.Backfill {
+		backfiller, err = NewBackfiller(apiKey, opts, ch)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &FastlyStatsProvider{
 		fastlyClient: fastlyClient,
+		backfiller:   backfiller,
 		service:      service,
+		opts:         opts,
 		ch:           ch,
+		status: ProviderStatus{
+			Service: service,
+			State:   StateStarting,
+			Since:   time.Now(),
+		},
 	}, nil
 }
 
+// Run polls until the context is done, or until Fastly rejects the API key.
 func (f *FastlyStatsProvider) Run(ctx context.Context) {
-	ll := zap.S()
+	ll := zap.S().With("service", f.service)
 	ll.Infof("starting fastly stats provider")
+	f.resume()
+	f.backfill(ctx)
+
+	if f.opts.Streaming {
+		f.stream(ctx)
+		return
+	}
+
+	f.loop(ctx, f.opts.PollInterval, func() error {
+		return f.next(ctx, f.ch)
+	})
+}
+
+// loop calls step every interval until the context is done or Fastly
+// rejects the API key. Failures are retried according to their kind.
+func (f *FastlyStatsProvider) loop(ctx context.Context, interval time.Duration, step func() error) {
+	ll := zap.S().With("service", f.service)
+
+	b := backoff{initial: time.Second, max: time.Minute}
 	for {
 		start := time.Now()
-		if err := f.next(ctx, f.ch); err != nil && !errors.Is(err, context.Canceled) {
-			ll.Warnf("failed to get stats, retrying in a while: %v", err)
+		err := step()
+		delay := interval - time.Since(start)
+
+		var httpErr *fastly.HTTPError
+		switch {
+		case err == nil:
+			b.reset()
+			f.setState(StateHealthy, nil)
+			if f.throttle > delay {
+				ll.Warnf("fastly rate limit used up, waiting %v", f.throttle)
+				delay = f.throttle
+			}
+
+		case errors.Is(err, context.Canceled):
+			return
+
+		case errors.Is(err, ErrUnauthorized):
+			f.setState(StateUnauthorized, err)
+			ll.Errorf("fastly rejected the API key, stopping the provider: %v", err)
+			return
+
+		case errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusTooManyRequests:
+			_, retryAfter := isRetryable(err)
+			delay = retryAfter
+			if delay == 0 {
+				delay = b.next()
+			}
+			f.setState(StateRateLimited, err)
+			ll.Warnf("rate limited by fastly, retrying in %v", delay)
+
+		default:
+			delay = b.next()
+			f.setState(StateBackingOff, err)
+			ll.Warnf("failed to get stats, retrying in %v: %v", delay, err)
+		}
+
+		ll.Debugf("getting stats took %v - sleeping for %v", time.Since(start), delay)
+
+		if err := sleep(ctx, delay); err != nil {
+			return
 		}
-		dur := time.Since(start)
+	}
+}
+
+// stream fetches continuously, buffering the samples, and publishes them on
+// boundaries aligned to multiples of the poll interval on the wall clock.
+// Every window is published streamDelay after it has ended, as the last
+// seconds arrive late. Samples arriving even later go in the next window,
+// see publish.
+func (f *FastlyStatsProvider) stream(ctx context.Context) {
+	ll := zap.S().With("service", f.service)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	samples := make(chan []*realtimeSample)
+	go func() {
+		defer close(samples)
+		f.loop(ctx, time.Second, func() error {
+			data, err := f.fetch()
+			if err != nil || len(data) == 0 {
+				return err
+			}
+
+			select {
+			case samples <- data:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
 
-		ll.Debugf("getting and reporting stats took %v - sleeping for %v", dur, pollInterval-dur)
+	boundary := time.Now().Truncate(f.opts.PollInterval).Add(f.opts.PollInterval)
+	timer := time.NewTimer(time.Until(boundary.Add(streamDelay)))
+	defer timer.Stop()
 
+	var buffer []*realtimeSample
+	for {
 		select {
-		case <-time.After(pollInterval - dur):
+		case data, ok := <-samples:
+			if !ok {
+				return
+			}
+			buffer = append(buffer, data...)
+
+		case <-timer.C:
+			var due, rest []*realtimeSample
+			var current bool
+			for _, rtdata := range buffer {
+				if rtdata.Recorded < uint64(boundary.Unix()) {
+					due = append(due, rtdata)
+					current = current || rtdata.Recorded > f.lastRecorded
+				} else {
+					rest = append(rest, rtdata)
+				}
+			}
+
+			if !current {
+				// Only late seconds, if any, which wait for a window that
+				// has seconds of its own
+				ll.Warnf("no new seconds before %s, not publishing", boundary.UTC().Format(time.RFC3339))
+			} else {
+				buffer = rest
+				if err := f.publish(ctx, due); err != nil {
+					return
+				}
+			}
+
+			boundary = boundary.Add(f.opts.PollInterval)
+			timer.Reset(time.Until(boundary.Add(streamDelay)))
+
 		case <-ctx.Done():
 			return
 		}
+	}
+}
 
-		if ctx.Err() != nil {
-			return
+// Status implements StatusReporter.
+func (f *FastlyStatsProvider) Status() []ProviderStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return []ProviderStatus{f.status}
+}
+
+// setState records the state of the provider, logging when it changes.
+func (f *FastlyStatsProvider) setState(state ProviderState, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.status.State != state {
+		zap.S().With("service", f.service).Infof("provider state changed from %s to %s", f.status.State, state)
+		f.status.State = state
+		f.status.Since = time.Now()
+	}
+
+	f.status.Error = ""
+	if err != nil {
+		f.status.Error = err.Error()
+	}
+}
+
+// getRealtimeStats fetches the realtime stats after timestamp, along with
+// its samples. Failures are
+// classified: rejected credentials wrap ErrUnauthorized, and rate limiting
+// and server errors are retryable, carrying the delay Fastly asked for.
+func (f *FastlyStatsProvider) getRealtimeStats(timestamp uint64) (*fastly.RealtimeStatsResponse, []*realtimeSample, error) {
+	resp, err := f.fastlyClient.Get(fmt.Sprintf("/v1/channel/%s/ts/%d", f.service, timestamp), nil)
+	if resp != nil {
+		defer resp.Body.Close()
+		f.throttle = rateLimitWait(resp.Header)
+	}
+	if err != nil {
+		var httpErr *fastly.HTTPError
+		if !errors.As(err, &httpErr) {
+			return nil, nil, &retryableError{err: err}
 		}
+
+		switch {
+		case httpErr.StatusCode == http.StatusUnauthorized || httpErr.StatusCode == http.StatusForbidden:
+			return nil, nil, fmt.Errorf("%w: %v", ErrUnauthorized, err)
+		case httpErr.StatusCode == http.StatusTooManyRequests:
+			retryAfter := parseRetryAfter(resp.Header)
+			if retryAfter == 0 {
+				retryAfter = parseRateLimitReset(resp.Header)
+			}
+			return nil, nil, &retryableError{err: err, retryAfter: retryAfter}
+		case httpErr.StatusCode >= 500:
+			return nil, nil, &retryableError{err: err}
+		}
+
+		return nil, nil, err
 	}
+
+	var data map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, nil, err
+	}
+
+	stats, samples, err := decodeRealtimeStats(data, f.opts.AllFields)
+	if err != nil {
+		return nil, nil, err
+	}
+	if stats.Error != "" {
+		return nil, nil, fmt.Errorf("realtime stats: %s", stats.Error)
+	}
+
+	return stats, samples, nil
+}
+
+// resume starts from the stored checkpoint, if there is one.
+func (f *FastlyStatsProvider) resume() {
+	if f.opts.Checkpoints == nil {
+		return
+	}
+
+	ll := zap.S().With("service", f.service)
+	ts, err := f.opts.Checkpoints.Load(f.service)
+	if err != nil {
+		ll.Warnf("failed to load checkpoint, starting from now: %v", err)
+		return
+	}
+	if ts == 0 {
+		ll.Infof("no checkpoint, starting from now")
+		return
+	}
+
+	ll.Infof("resuming from checkpoint %d", ts)
+	f.timestamp = ts
+	f.lastFetched = ts
+	f.lastRecorded = ts
+	f.resumedFrom = ts
+}
+
+// backfill publishes the minutes since the checkpoint that are no longer in
+// the realtime stats, and moves the checkpoint past them.
+func (f *FastlyStatsProvider) backfill(ctx context.Context) {
+	if f.backfiller == nil || f.timestamp == 0 {
+		return
+	}
+
+	until := uint64(time.Now().Add(-RealtimeRetention).Unix())
+	if f.timestamp >= until {
+		return
+	}
+
+	last, err := f.backfiller.Backfill(ctx, f.service, f.timestamp, until)
+	if err != nil {
+		zap.S().With("service", f.service).Warnf("failed to backfill from historical stats: %v", err)
+	}
+	if last > f.timestamp {
+		f.timestamp = last
+		f.lastFetched = last
+		f.lastRecorded = last
+		f.resumedFrom = last
+	}
+}
+
+// accountResume logs how many seconds since the checkpoint were recovered,
+// and how many are lost because Fastly no longer had them. Responses
+// without data tell neither, so they are left for the next one.
+func (f *FastlyStatsProvider) accountResume(data []*realtimeSample) {
+	if f.resumedFrom == 0 || len(data) == 0 {
+		return
+	}
+	checkpoint := f.resumedFrom
+	f.resumedFrom = 0
+
+	var recovered uint64
+	first := uint64(math.MaxUint64)
+	for _, rtdata := range data {
+		if rtdata.Recorded > checkpoint {
+			recovered++
+		}
+		if rtdata.Recorded < first {
+			first = rtdata.Recorded
+		}
+	}
+
+	var lost uint64
+	if first > checkpoint+1 {
+		lost = first - checkpoint - 1
+	}
+
+	zap.S().With("service", f.service).Infof("resumed from checkpoint %d: recovered %d seconds, lost %d seconds", checkpoint, recovered, lost)
 }
 
-func (f *FastlyStatsProvider) mean(list []*fastly.RealtimeData) *FastlyMeanStats {
-	stats := &fastly.Stats{}
+// mean aggregates list, which must not be empty.
+func (f *FastlyStatsProvider) mean(list []*realtimeSample) *FastlyMeanStats {
 	n := uint64(len(list))
 
 	var min, max uint64 = math.MaxUint64, 0
 
-	refStats := reflect.ValueOf(stats)
+	aggregated := make([]*fastly.Stats, 0, len(list))
+	values := make([]StatValues, 0, len(list))
+	datacenters := map[string][]StatValues{}
 
-	for _, rtdata := range list {
-		vs := reflect.ValueOf(rtdata.Aggregated)
-		for i := 0; i < vs.Elem().NumField(); i++ {
-			sf := vs.Elem().Field(i)
-			df := refStats.Elem().Field(i)
+	for _, sample := range list {
+		aggregated = append(aggregated, sample.Aggregated)
+		values = append(values, sample.Values)
 
-			switch sf.Kind() {
-			case reflect.Uint64:
-				df.SetUint(sf.Uint() + df.Uint())
-			case reflect.Float64:
-				df.SetFloat(sf.Float() + df.Float())
+		if f.opts.PerPOP {
+			for pop, v := range sample.Datacenters {
+				datacenters[pop] = append(datacenters[pop], v)
 			}
 		}
 
-		if rtdata.Recorded < min {
-			min = rtdata.Recorded
+		if sample.Recorded < min {
+			min = sample.Recorded
 		}
 
-		if rtdata.Recorded > max {
-			max = rtdata.Recorded
-		}
-	}
-	for i := 0; i < refStats.Elem().NumField(); i++ {
-		f := refStats.Elem().Field(i)
-		switch f.Kind() {
-		case reflect.Uint64:
-			f.SetUint(uint64(math.Round(float64(f.Uint()) / float64(n))))
-		case reflect.Float64:
-			f.SetFloat(f.Float() / float64(n))
+		if sample.Recorded > max {
+			max = sample.Recorded
 		}
 	}
 
-	// Hit Ratio is not set in RT API, build it synthetically
-	stats.HitRatio = float64(stats.Hits) / float64(stats.Hits+stats.Miss)
-
-	return &FastlyMeanStats{
+	stats, aggregatedValues, totals := aggregateOf(values, n, f.opts.Aggregations)
+	meanStats := &FastlyMeanStats{
+		Service:       f.service,
 		IntervalStart: min,
 		IntervalEnd:   max,
 		Stats:         stats,
+		Values:        aggregatedValues,
+		Totals:        totals,
+		SampleCount:   n,
+		Distributions: distributionsOf(values, f.opts.DistributionFields),
+		MissHistogram: mergeMissHistograms(aggregated),
+	}
+
+	if f.opts.PerPOP {
+		meanStats.Datacenters = make(map[string]*fastly.Stats, len(datacenters))
+		meanStats.DatacenterValues = make(map[string]StatValues, len(datacenters))
+		meanStats.DatacenterTotals = make(map[string]StatValues, len(datacenters))
+		for pop, list := range datacenters {
+			meanStats.Datacenters[pop], meanStats.DatacenterValues[pop], meanStats.DatacenterTotals[pop] = aggregateOf(list, n, f.opts.Aggregations)
+		}
 	}
+	meanStats.derive(f.opts.DerivedMetrics)
+
+	return meanStats
 }
 
-func (s *FastlyStatsProvider) next(ctx context.Context, ch chan<- *FastlyMeanStats) error {
-	ll := zap.S()
-	req := &fastly.GetRealtimeStatsInput{
-		ServiceID: s.service,
-		Timestamp: s.timestamp,
+// fresh returns the samples recorded after the last fetched second, so
+// that seconds returned again are not published twice.
+func (s *FastlyStatsProvider) fresh(data []*realtimeSample) []*realtimeSample {
+	result := make([]*realtimeSample, 0, len(data))
+	for _, rtdata := range data {
+		if rtdata.Recorded > s.lastFetched {
+			result = append(result, rtdata)
+		}
+	}
+
+	if dup := len(data) - len(result); dup > 0 {
+		zap.S().With("service", s.service).Warnf("dropped %d seconds that were already published", dup)
+	}
+
+	return result
+}
+
+// lateSeconds counts the samples of data recorded no later than the last
+// published second.
+func (s *FastlyStatsProvider) lateSeconds(data []*realtimeSample) int {
+	var late int
+	for _, rtdata := range data {
+		if rtdata.Recorded <= s.lastRecorded {
+			late++
+		}
 	}
 
+	return late
+}
+
+// missingSeconds counts the seconds without data within the interval of
+// snapshot and between it and the previous one. Late seconds folded into
+// the snapshot count against it, as they were missing from the snapshot
+// they belong to, so that the sum over all snapshots is the number of
+// seconds never received.
+func (s *FastlyStatsProvider) missingSeconds(snapshot *FastlyMeanStats) uint64 {
+	var missing uint64
+	if span := snapshot.IntervalEnd - snapshot.IntervalStart + 1; span > snapshot.SampleCount {
+		missing = span - snapshot.SampleCount
+	}
+	if s.lastRecorded != 0 && snapshot.IntervalStart > s.lastRecorded+1 {
+		missing += snapshot.IntervalStart - s.lastRecorded - 1
+	}
+
+	return missing
+}
+
+// fetch gets the realtime stats since the last fetch, and returns the
+// seconds that have not been fetched before.
+func (s *FastlyStatsProvider) fetch() ([]*realtimeSample, error) {
+	ll := zap.S().With("service", s.service)
 	ll.Debugf("getting realtime stats")
-	resp, err := s.fastlyClient.GetRealtimeStats(req)
+	resp, samples, err := s.getRealtimeStats(s.timestamp)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	ll.Debugf("got %d seconds worth of value", len(resp.Data))
+	ll.Debugf("got %d seconds worth of value", len(samples))
+	s.accountResume(samples)
 
-	meanStats := s.mean(resp.Data)
+	if s.timestamp != 0 && resp.Timestamp == s.timestamp {
+		s.repeated++
+		ll.Warnf("realtime timestamp %d has not advanced for %d polls", resp.Timestamp, s.repeated)
+	} else {
+		s.repeated = 0
+	}
 	s.timestamp = resp.Timestamp
 
-	select {
-	case ch <- meanStats:
-	case <-ctx.Done():
-		return ctx.Err()
+	data := s.fresh(samples)
+	for _, rtdata := range data {
+		if rtdata.Recorded > s.lastFetched {
+			s.lastFetched = rtdata.Recorded
+		}
+	}
+
+	return data, nil
+}
+
+// next fetches and publishes the seconds since the last poll.
+func (s *FastlyStatsProvider) next(ctx context.Context, ch chan<- *FastlyMeanStats) error {
+	data, err := s.fetch()
+	if err != nil {
+		return err
+	}
+
+	if len(data) == 0 {
+		zap.S().With("service", s.service).Warnf("no new seconds in realtime response, not publishing")
+		return nil
+	}
+
+	return s.publish(ctx, data)
+}
+
+// publish aggregates data into snapshots and sends them.
+func (s *FastlyStatsProvider) publish(ctx context.Context, data []*realtimeSample) error {
+	ll := zap.S().With("service", s.service)
+
+	var snapshots []*FastlyMeanStats
+	if s.opts.Raw {
+		for _, rtdata := range data {
+			sample := s.mean([]*realtimeSample{rtdata})
+			sample.Raw = true
+			sample.Distributions = nil
+			snapshots = append(snapshots, sample)
+		}
+	}
+	snapshot := s.mean(data)
+	if s.lastRecorded != 0 && snapshot.IntervalStart <= s.lastRecorded {
+		// Seconds that arrived after their window was published are folded
+		// into this one, which must not overlap the previous interval
+		ll.Infof("%d seconds arrived late, adding them to the interval after %d", s.lateSeconds(data), s.lastRecorded)
+		snapshot.IntervalStart = s.lastRecorded + 1
+	}
+	snapshot.MissingSeconds = s.missingSeconds(snapshot)
+	if snapshot.MissingSeconds > 0 {
+		ll.Warnf("%d seconds missing in the realtime stats up to %d", snapshot.MissingSeconds, snapshot.IntervalEnd)
+	}
+	snapshots = append(snapshots, snapshot)
+	s.lastRecorded = snapshot.IntervalEnd
+
+	now := time.Now()
+	for _, snapshot := range snapshots {
+		snapshot.Lag = now.Sub(time.Unix(int64(snapshot.IntervalEnd)+1, 0))
 	}
 
+	for _, snapshot := range snapshots {
+		select {
+		case s.ch <- snapshot:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	s.saveCheckpoint()
+
 	return nil
 }
+
+// saveCheckpoint stores the last published second.
+func (s *FastlyStatsProvider) saveCheckpoint() {
+	if s.opts.Checkpoints == nil {
+		return
+	}
+
+	if err := s.opts.Checkpoints.Save(s.service, s.lastRecorded); err != nil {
+		zap.S().With("service", s.service).Warnf("failed to save checkpoint: %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package fastlystats
 
 import (
+	"math"
 	"reflect"
 
 	"go.uber.org/zap"
@@ -23,6 +24,10 @@ var int64Valuer valuer = func(v reflect.Value) *monitoringpb.TypedValue {
 	case reflect.Int64:
 		vv = int64(v.Interface().(int64))
 	case reflect.Uint:
+		if v.Uint() > math.MaxInt64 {
+			zap.S().Warnf("value %d overflows 'int64'", v.Uint())
+			return nil
+		}
 		vv = int64(v.Interface().(uint))
 	case reflect.Uint8:
 		vv = int64(v.Interface().(uint8))
@@ -31,6 +36,10 @@ var int64Valuer valuer = func(v reflect.Value) *monitoringpb.TypedValue {
 	case reflect.Uint32:
 		vv = int64(v.Interface().(uint32))
 	case reflect.Uint64:
+		if v.Uint() > math.MaxInt64 {
+			zap.S().Warnf("value %d overflows 'int64'", v.Uint())
+			return nil
+		}
 		vv = int64(v.Interface().(uint64))
 	default:
 		zap.S().Warnf("bad value for 'int64' with kind %v", v.Kind())
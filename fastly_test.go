@@ -0,0 +1,59 @@
+package fastlystats
+
+import (
+	"testing"
+
+	"github.com/fastly/go-fastly/v3/fastly"
+)
+
+func TestMissingSeconds(t *testing.T) {
+	tests := []struct {
+		name         string
+		lastRecorded uint64
+		start, end   uint64
+		samples      uint64
+		want         uint64
+	}{
+		{"complete", 100, 101, 115, 15, 0},
+		{"first", 0, 101, 115, 14, 1},
+		{"gap inside", 100, 101, 115, 13, 2},
+		{"gap before", 100, 104, 115, 12, 3},
+		{"late seconds folded in", 100, 101, 115, 17, 0},
+		{"late seconds and gap inside", 100, 101, 115, 14, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &FastlyStatsProvider{lastRecorded: tt.lastRecorded}
+			snapshot := &FastlyMeanStats{IntervalStart: tt.start, IntervalEnd: tt.end, SampleCount: tt.samples}
+			if got := s.missingSeconds(snapshot); got != tt.want {
+				t.Errorf("missingSeconds() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLateSeconds(t *testing.T) {
+	s := &FastlyStatsProvider{lastRecorded: 100}
+	var data []*realtimeSample
+	for _, recorded := range []uint64{99, 100, 101, 102} {
+		data = append(data, &realtimeSample{RealtimeData: &fastly.RealtimeData{Recorded: recorded}})
+	}
+	if got := s.lateSeconds(data); got != 2 {
+		t.Errorf("lateSeconds() = %d, want 2", got)
+	}
+}
+
+func TestAccountResumeWaitsForData(t *testing.T) {
+	f := &FastlyStatsProvider{resumedFrom: 100}
+
+	f.accountResume(nil)
+	if f.resumedFrom != 100 {
+		t.Fatalf("resume accounted for on a response without data")
+	}
+
+	f.accountResume([]*realtimeSample{{RealtimeData: &fastly.RealtimeData{Recorded: 120}}})
+	if f.resumedFrom != 0 {
+		t.Errorf("resume not accounted for on a response with data")
+	}
+}
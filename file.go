@@ -0,0 +1,101 @@
+package fastlystats
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"go.uber.org/zap"
+)
+
+func init() {
+	RegisterExporter("file", func(cfg *Config, ch <-chan *FastlyMeanStats) (Exporter, error) {
+		if cfg.FileSinkPath == "" {
+			return nil, ErrSinkDisabled
+		}
+		opts, err := cfg.ExportOptions("file")
+		if err != nil {
+			return nil, err
+		}
+		return NewFileExporter(cfg.FileSinkPath, opts, ch)
+	})
+}
+
+// fileRecord is one line written by the FileExporter.
+type fileRecord struct {
+	Service       string                `json:"service"`
+	Raw           bool                  `json:"raw"`
+	IntervalStart uint64                `json:"interval_start"`
+	IntervalEnd   uint64                `json:"interval_end"`
+	SampleCount   uint64                `json:"sample_count"`
+	Missing       uint64                `json:"missing_seconds"`
+	Lag           float64               `json:"lag_seconds"`
+	Values        StatValues            `json:"values"`
+	Datacenters   map[string]StatValues `json:"datacenters,omitempty"`
+}
+
+// FileExporter appends every snapshot to a file as a line of JSON, which is
+// mostly useful together with raw samples for incident forensics.
+type FileExporter struct {
+	path string
+	opts ExportOptions
+	ch   <-chan *FastlyMeanStats
+}
+
+func NewFileExporter(path string, opts ExportOptions, ch <-chan *FastlyMeanStats) (*FileExporter, error) {
+	return &FileExporter{
+		path: path,
+		opts: opts,
+		ch:   ch,
+	}, nil
+}
+
+// AcceptsRaw implements RawAccepter.
+func (f *FileExporter) AcceptsRaw() bool {
+	return true
+}
+
+func (f *FileExporter) Run(ctx context.Context) {
+	ll := zap.S()
+	ll.Infof("starting file exporter to %s", f.path)
+
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		ll.Errorf("failed to open %s: %v", f.path, err)
+		return
+	}
+	defer file.Close()
+
+	enc := json.NewEncoder(file)
+	for {
+		select {
+		case s, ok := <-f.ch:
+			if !ok {
+				ll.Infof("channel closed, not exporting any more stats")
+				return
+			}
+
+			datacenters := make(map[string]StatValues, len(s.DatacenterValues))
+			for pop, values := range s.DatacenterValues {
+				datacenters[pop] = f.opts.exportedValues(values)
+			}
+
+			err := enc.Encode(fileRecord{
+				Service:       s.Service,
+				Raw:           s.Raw,
+				IntervalStart: s.IntervalStart,
+				IntervalEnd:   s.IntervalEnd,
+				SampleCount:   s.SampleCount,
+				Missing:       s.MissingSeconds,
+				Lag:           s.Lag.Seconds(),
+				Values:        f.opts.exportedValues(s.Values),
+				Datacenters:   datacenters,
+			})
+			if err != nil {
+				ll.Errorf("failed to write to %s: %v", f.path, err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package fastlystats
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// FieldFilter selects fields by name with glob patterns as understood by
+// path.Match, e.g. "*_bytes". A field passes if it matches an include
+// pattern, or there are none, and matches no exclude pattern.
+type FieldFilter struct {
+	Include []string
+	Exclude []string
+}
+
+func NewFieldFilter(include, exclude []string) (FieldFilter, error) {
+	for _, patterns := range [][]string{include, exclude} {
+		for _, p := range patterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return FieldFilter{}, fmt.Errorf("bad pattern %q: %w", p, err)
+			}
+		}
+	}
+
+	return FieldFilter{
+		Include: include,
+		Exclude: exclude,
+	}, nil
+}
+
+// Match reports whether name passes the filter.
+func (f FieldFilter) Match(name string) bool {
+	if len(f.Include) > 0 && !matchAny(f.Include, name) {
+		return false
+	}
+
+	return !matchAny(f.Exclude, name)
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sinkPatterns returns the patterns of sink from entries of the form
+// "sink:pattern". Entries of sinks that are not registered are an error.
+func sinkPatterns(entries []string, sink string) ([]string, error) {
+	var patterns []string
+	for _, entry := range entries {
+		name, pattern, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("bad entry %q, must be sink:pattern", entry)
+		}
+		if err := checkSink(name); err != nil {
+			return nil, fmt.Errorf("bad entry %q: %w", entry, err)
+		}
+		if name == sink {
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	return patterns, nil
+}
+
+// exports reports whether the field name is exported: it is enabled in the
+// catalog and passes every field filter.
+func (o ExportOptions) exports(name string) bool {
+	if !isEnabled(name) {
+		return false
+	}
+	for _, f := range o.Fields {
+		if !f.Match(name) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// exportedValues returns the values of the fields that are exported.
+func (o ExportOptions) exportedValues(values StatValues) StatValues {
+	result := make(StatValues, len(values))
+	for name, v := range values {
+		if o.exports(name) {
+			result[name] = v
+		}
+	}
+
+	return result
+}
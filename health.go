@@ -0,0 +1,135 @@
+package fastlystats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ProviderState is what a provider is currently doing.
+type ProviderState string
+
+const (
+	// StateStarting is the state until the first poll has finished.
+	StateStarting = ProviderState("starting")
+	// StateHealthy means the last poll succeeded.
+	StateHealthy = ProviderState("healthy")
+	// StateBackingOff means polls are failing and retried with backoff.
+	StateBackingOff = ProviderState("backing_off")
+	// StateRateLimited means Fastly asked to slow down.
+	StateRateLimited = ProviderState("rate_limited")
+	// StateUnauthorized means Fastly rejected the API key, the provider has
+	// stopped.
+	StateUnauthorized = ProviderState("unauthorized")
+)
+
+// ProviderStatus is the state of the provider of one service.
+type ProviderStatus struct {
+	Service string        `json:"service"`
+	State   ProviderState `json:"state"`
+	Since   time.Time     `json:"since"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// StatusReporter is implemented by providers that report the state of the
+// services they poll.
+type StatusReporter interface {
+	Status() []ProviderStatus
+}
+
+// HealthServer serves the state of all providers on /healthz, along with
+// the snapshots dropped by the fanout and the values rejected by the
+// sanitizer.
+type HealthServer struct {
+	listenAddr string
+	reporters  []StatusReporter
+	fanout     *Fanout
+	sanitizer  *Sanitizer
+}
+
+// NewHealthServer creates a server reporting the state of reporters. The
+// fanout and sanitizer may be nil.
+func NewHealthServer(listenAddr string, reporters []StatusReporter, fanout *Fanout, sanitizer *Sanitizer) *HealthServer {
+	return &HealthServer{
+		listenAddr: listenAddr,
+		reporters:  reporters,
+		fanout:     fanout,
+		sanitizer:  sanitizer,
+	}
+}
+
+type healthResponse struct {
+	Healthy   bool             `json:"healthy"`
+	Providers []ProviderStatus `json:"providers"`
+
+	// Dropped is the number of snapshots dropped since startup, per sink.
+	Dropped map[string]uint64 `json:"dropped"`
+
+	// Rejected is the number of values rejected by the sanitizer since
+	// startup, by field and reason.
+	Rejected map[string]map[RejectReason]uint64 `json:"rejected"`
+}
+
+// ServeHTTP responds with the state of every provider. The status is 503 if
+// any of them has stopped because it is unauthorized, and 200 otherwise, as
+// backing off is expected to recover on its own.
+func (h *HealthServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	resp := healthResponse{Healthy: true, Providers: []ProviderStatus{}, Rejected: map[string]map[RejectReason]uint64{}}
+	resp.Dropped = map[string]uint64{}
+	if h.fanout != nil {
+		resp.Dropped = h.fanout.Dropped()
+	}
+	if h.sanitizer != nil {
+		resp.Rejected = h.sanitizer.Rejected()
+	}
+	for _, reporter := range h.reporters {
+		resp.Providers = append(resp.Providers, reporter.Status()...)
+	}
+	sort.Slice(resp.Providers, func(i, j int) bool {
+		return resp.Providers[i].Service < resp.Providers[j].Service
+	})
+
+	for _, status := range resp.Providers {
+		if status.State == StateUnauthorized {
+			resp.Healthy = false
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !resp.Healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Run serves /healthz until the context is done or the server fails, e.g.
+// because the address is in use.
+func (h *HealthServer) Run(ctx context.Context) {
+	ll := zap.S()
+	ll.Infof("starting health endpoint on %s", h.listenAddr)
+
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", h)
+
+	server := &http.Server{
+		Addr:              h.listenAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		ll.Errorf("health server failed: %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package fastlystats
+
+import (
+	"sort"
+
+	"github.com/fastly/go-fastly/v3/fastly"
+)
+
+// missHistogramBounds are the upper bounds, in milliseconds, of the buckets
+// of Fastly's miss histogram: 10ms wide up to 1s, 50ms wide up to 3s, 500ms
+// wide up to 10s and 5s wide up to 60s. Fetches slower than that are counted
+// in the last bucket.
+var missHistogramBounds = func() []float64 {
+	var bounds []float64
+	for _, span := range []struct{ width, until int }{
+		{10, 1000},
+		{50, 3000},
+		{500, 10000},
+		{5000, 60000},
+	} {
+		from := 0
+		if len(bounds) > 0 {
+			from = int(bounds[len(bounds)-1])
+		}
+		for bound := from + span.width; bound <= span.until; bound += span.width {
+			bounds = append(bounds, float64(bound))
+		}
+	}
+
+	return bounds
+}()
+
+// LatencyHistogram counts origin fetches by time to first byte. Bounds holds
+// the upper bound of every bucket in milliseconds, ascending, and Counts[i]
+// the number of fetches in (Bounds[i-1], Bounds[i]]. The buckets are always
+// those of missHistogramBounds, empty or not.
+type LatencyHistogram struct {
+	Bounds []float64
+	Counts []uint64
+	Total  uint64
+}
+
+// missBucket returns the index of the bucket of a Fastly histogram key. Keys
+// that are not a bound of the layout go in the bucket they fall in.
+func missBucket(key int) int {
+	i := sort.SearchFloat64s(missHistogramBounds, float64(key))
+	if i == len(missHistogramBounds) {
+		return i - 1
+	}
+
+	return i
+}
+
+// mergeMissHistograms adds up the miss histograms of all samples. It
+// returns nil if there were no misses.
+func mergeMissHistograms(list []*fastly.Stats) *LatencyHistogram {
+	h := &LatencyHistogram{
+		Bounds: missHistogramBounds,
+		Counts: make([]uint64, len(missHistogramBounds)),
+	}
+	for _, s := range list {
+		for key, count := range s.MissHistogram {
+			if count > 0 {
+				h.Counts[missBucket(key)] += uint64(count)
+				h.Total += uint64(count)
+			}
+		}
+	}
+
+	if h.Total == 0 {
+		return nil
+	}
+
+	return h
+}
+
+// lower returns the lower bound of bucket i.
+func (h *LatencyHistogram) lower(i int) float64 {
+	if i == 0 {
+		return 0
+	}
+
+	return h.Bounds[i-1]
+}
+
+// Min returns the lower bound of the first bucket with fetches.
+func (h *LatencyHistogram) Min() float64 {
+	for i, c := range h.Counts {
+		if c > 0 {
+			return h.lower(i)
+		}
+	}
+
+	return 0
+}
+
+// Max returns the upper bound of the last bucket with fetches.
+func (h *LatencyHistogram) Max() float64 {
+	for i := len(h.Counts) - 1; i >= 0; i-- {
+		if h.Counts[i] > 0 {
+			return h.Bounds[i]
+		}
+	}
+
+	return 0
+}
+
+// Mean approximates the mean latency, assuming each fetch took the middle of
+// its bucket.
+func (h *LatencyHistogram) Mean() float64 {
+	var sum float64
+	for i, c := range h.Counts {
+		sum += float64(c) * (h.lower(i) + h.Bounds[i]) / 2
+	}
+
+	return sum / float64(h.Total)
+}
+
+// SumOfSquaredDeviation approximates the sum of squared differences from
+// Mean, with the same assumption as Mean.
+func (h *LatencyHistogram) SumOfSquaredDeviation() float64 {
+	mean := h.Mean()
+
+	var sum float64
+	for i, c := range h.Counts {
+		d := (h.lower(i)+h.Bounds[i])/2 - mean
+		sum += float64(c) * d * d
+	}
+
+	return sum
+}
+
+// Percentile estimates the p-th percentile latency, interpolating linearly
+// within the bucket it falls in.
+func (h *LatencyHistogram) Percentile(p float64) float64 {
+	rank := p / 100 * float64(h.Total)
+
+	var seen float64
+	for i, c := range h.Counts {
+		if c == 0 {
+			continue
+		}
+		if seen+float64(c) >= rank {
+			lower := h.lower(i)
+			return lower + (h.Bounds[i]-lower)*(rank-seen)/float64(c)
+		}
+		seen += float64(c)
+	}
+
+	return h.Max()
+}
@@ -0,0 +1,105 @@
+package fastlystats
+
+import (
+	"math"
+	"testing"
+
+	"github.com/fastly/go-fastly/v3/fastly"
+)
+
+func TestMissHistogramBounds(t *testing.T) {
+	if got := len(missHistogramBounds); got != 164 {
+		t.Fatalf("got %d buckets, want 164", got)
+	}
+
+	for _, tt := range []struct {
+		key  int
+		want float64
+	}{
+		{0, 10},
+		{10, 10},
+		{15, 20},
+		{1000, 1000},
+		{1050, 1050},
+		{3500, 3500},
+		{15000, 15000},
+		{60000, 60000},
+		{70000, 60000},
+	} {
+		if got := missHistogramBounds[missBucket(tt.key)]; got != tt.want {
+			t.Errorf("key %d: got bucket %g, want %g", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestLatencyHistogramSparse(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		histogram map[int]int
+		mean      float64
+		p50       float64
+		p99       float64
+		min, max  float64
+	}{
+		{
+			name:      "single bucket",
+			histogram: map[int]int{500: 4},
+			mean:      495,
+			p50:       495,
+			p99:       499.9,
+			min:       490,
+			max:       500,
+		},
+		{
+			name:      "empty buckets in between",
+			histogram: map[int]int{10: 1, 500: 1},
+			mean:      250,
+			p50:       10,
+			p99:       499.8,
+			min:       0,
+			max:       500,
+		},
+		{
+			name:      "wider buckets",
+			histogram: map[int]int{20: 2, 2000: 1, 15000: 1},
+			mean:      (2*15 + 1975 + 12500) / 4.0,
+			p50:       20,
+			p99:       10000 + 5000*0.96,
+			min:       10,
+			max:       15000,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			h := mergeMissHistograms([]*fastly.Stats{{MissHistogram: tt.histogram}})
+			for _, c := range []struct {
+				what      string
+				got, want float64
+			}{
+				{"mean", h.Mean(), tt.mean},
+				{"p50", h.Percentile(50), tt.p50},
+				{"p99", h.Percentile(99), tt.p99},
+				{"min", h.Min(), tt.min},
+				{"max", h.Max(), tt.max},
+			} {
+				if math.Abs(c.got-c.want) > 1e-9 {
+					t.Errorf("%s: got %g, want %g", c.what, c.got, c.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMergeMissHistogramsSameBounds(t *testing.T) {
+	a := mergeMissHistograms([]*fastly.Stats{{MissHistogram: map[int]int{10: 1}}})
+	b := mergeMissHistograms([]*fastly.Stats{{MissHistogram: map[int]int{500: 3}}, {MissHistogram: map[int]int{500: 1}}})
+	if len(a.Bounds) != len(b.Bounds) || len(a.Counts) != len(a.Bounds) {
+		t.Fatalf("bounds differ: %d and %d buckets", len(a.Bounds), len(b.Bounds))
+	}
+	if b.Total != 4 {
+		t.Errorf("got total %d, want 4", b.Total)
+	}
+
+	if h := mergeMissHistograms([]*fastly.Stats{{}}); h != nil {
+		t.Errorf("got %v without misses, want nil", h)
+	}
+}
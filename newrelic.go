@@ -7,8 +7,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
-	"reflect"
 	"time"
 
 	"go.uber.org/zap"
@@ -18,20 +18,39 @@ var ErrNotFound = errors.New("not found")
 
 const nrEndpoint = "https://metric-api.eu.newrelic.com/metric/v1"
 
+func init() {
+	RegisterExporter("newrelic", func(cfg *Config, ch <-chan *FastlyMeanStats) (Exporter, error) {
+		if cfg.NewRelicInsertKey == "" {
+			return nil, ErrSinkDisabled
+		}
+		opts, err := cfg.ExportOptions("newrelic")
+		if err != nil {
+			return nil, err
+		}
+		return NewNewRelicExporter(cfg.NewRelicInsertKey, cfg.NewRelicRetryDeadline, opts, ch)
+	})
+}
+
 type NewRelicMetricReport struct {
 	Metrics []NewRelicMetricDescriptor `json:"metrics"`
 }
 
 type NewRelicExporter struct {
-	insertKey  string
-	ch         <-chan *FastlyMeanStats
-	httpClient *http.Client
+	insertKey     string
+	retryDeadline time.Duration
+	opts          ExportOptions
+	ch            <-chan *FastlyMeanStats
+	httpClient    *http.Client
 }
 
-func NewNewRelicExporter(insertKey string, ch <-chan *FastlyMeanStats) (*NewRelicExporter, error) {
+// NewNewRelicExporter creates an exporter reporting with insertKey. Failed
+// submissions are retried until retryDeadline has passed.
+func NewNewRelicExporter(insertKey string, retryDeadline time.Duration, opts ExportOptions, ch <-chan *FastlyMeanStats) (*NewRelicExporter, error) {
 	return &NewRelicExporter{
-		insertKey: insertKey,
-		ch:        ch,
+		insertKey:     insertKey,
+		retryDeadline: retryDeadline,
+		opts:          opts,
+		ch:            ch,
 		httpClient: &http.Client{
 			Timeout: 10 * time.Second,
 		},
@@ -48,41 +67,217 @@ func getNewRelicMetric(name string) (NewRelicMetricDescriptor, error) {
 	return NewRelicMetricDescriptor{}, ErrNotFound
 }
 
+// withAttributes returns a copy of attrs with extra added. The descriptor
+// attributes are shared, so they must never be modified in place.
+func withAttributes(attrs map[string]string, extra map[string]string) map[string]string {
+	result := make(map[string]string, len(attrs)+len(extra))
+	for k, v := range attrs {
+		result[k] = v
+	}
+	for k, v := range extra {
+		result[k] = v
+	}
+
+	return result
+}
+
 func (n *NewRelicExporter) buildMetrics(s *FastlyMeanStats) []NewRelicMetricReport {
 	metrics := []NewRelicMetricReport{
-		{Metrics: make([]NewRelicMetricDescriptor, 0, len(NRMetricDescriptors))},
+		{Metrics: make([]NewRelicMetricDescriptor, 0, len(NRMetricDescriptors)*(len(s.Datacenters)+1))},
+	}
+
+	metrics[0].Metrics = n.appendMetrics(metrics[0].Metrics, s.Values, s.IntervalStart, map[string]string{
+		"service": s.Service,
+	})
+	for pop, values := range s.DatacenterValues {
+		metrics[0].Metrics = n.appendMetrics(metrics[0].Metrics, values, s.IntervalStart, map[string]string{
+			"service": s.Service,
+			"pop":     pop,
+		})
+	}
+	metrics[0].Metrics = n.appendDistributions(metrics[0].Metrics, s)
+	metrics[0].Metrics = n.appendMissHistogram(metrics[0].Metrics, s)
+	metrics[0].Metrics = n.appendFeed(metrics[0].Metrics, s)
+
+	return metrics
+}
+
+// appendDistributions adds a summary and percentile gauges for every
+// distribution of s.
+func (n *NewRelicExporter) appendDistributions(metrics []NewRelicMetricDescriptor, s *FastlyMeanStats) []NewRelicMetricDescriptor {
+	attrs := map[string]string{
+		"system":  "fastly",
+		"service": s.Service,
+	}
+	interval := int64(s.IntervalEnd+1-s.IntervalStart) * 1000
+
+	for name, d := range s.Distributions {
+		metrics = append(metrics, NewRelicMetricDescriptor{
+			Name: fmt.Sprintf("fastly.%s.distribution", name),
+			Type: NRSummary,
+			Value: NRSummaryValue{
+				Count: d.Count,
+				Sum:   d.Sum,
+				Min:   d.Min,
+				Max:   d.Max,
+			},
+			Timestamp:  int64(s.IntervalStart),
+			IntervalMs: interval,
+			Attributes: attrs,
+		})
+
+		for _, p := range []struct {
+			suffix string
+			value  float64
+		}{{"p50", d.P50}, {"p90", d.P90}, {"p95", d.P95}, {"p99", d.P99}, {"stddev", d.StdDev}} {
+			metrics = append(metrics, NewRelicMetricDescriptor{
+				Name:       fmt.Sprintf("fastly.%s.%s", name, p.suffix),
+				Type:       NRGauge,
+				Value:      p.value,
+				Timestamp:  int64(s.IntervalStart),
+				Attributes: attrs,
+			})
+		}
+	}
+
+	return metrics
+}
+
+// appendMissHistogram adds the miss latency as a summary, New Relic's
+// distribution type in the Metric API, and as percentile gauges.
+func (n *NewRelicExporter) appendMissHistogram(metrics []NewRelicMetricDescriptor, s *FastlyMeanStats) []NewRelicMetricDescriptor {
+	h := s.MissHistogram
+	if h == nil {
+		return metrics
+	}
+
+	attrs := map[string]string{
+		"system":  "fastly",
+		"service": s.Service,
+		"unit":    "ms",
+	}
+
+	metrics = append(metrics, NewRelicMetricDescriptor{
+		Name: "fastly.miss_latency",
+		Type: NRSummary,
+		Value: NRSummaryValue{
+			Count: h.Total,
+			Sum:   h.Mean() * float64(h.Total),
+			Min:   h.Min(),
+			Max:   h.Max(),
+		},
+		Timestamp:  int64(s.IntervalStart),
+		IntervalMs: int64(s.IntervalEnd+1-s.IntervalStart) * 1000,
+		Attributes: attrs,
+	})
+
+	for _, p := range missLatencyPercentiles {
+		metrics = append(metrics, NewRelicMetricDescriptor{
+			Name:       fmt.Sprintf("fastly.miss_latency.p%g", p),
+			Type:       NRGauge,
+			Value:      h.Percentile(p),
+			Timestamp:  int64(s.IntervalStart),
+			Attributes: attrs,
+		})
+	}
+
+	return metrics
+}
+
+// appendFeed adds gauges describing the realtime feed itself.
+func (n *NewRelicExporter) appendFeed(metrics []NewRelicMetricDescriptor, s *FastlyMeanStats) []NewRelicMetricDescriptor {
+	for _, m := range []struct {
+		name  string
+		unit  string
+		value interface{}
+	}{
+		{"data_lag", "s", s.Lag.Seconds()},
+		{"sample_count", "1", s.SampleCount},
+		{"missing_seconds", "s", s.MissingSeconds},
+	} {
+		metrics = append(metrics, NewRelicMetricDescriptor{
+			Name:      fmt.Sprintf("fastly.%s", m.name),
+			Type:      NRGauge,
+			Value:     m.value,
+			Timestamp: int64(s.IntervalStart),
+			Attributes: map[string]string{
+				"system":  "fastly",
+				"service": s.Service,
+				"unit":    m.unit,
+			},
+		})
 	}
-	t := reflect.TypeOf(*s.Stats)
-	v := reflect.ValueOf(*s.Stats)
-	for i := 0; i < t.NumField(); i++ {
-		f := t.Field(i)
 
-		name := f.Tag.Get("mapstructure")
+	return metrics
+}
+
+func (n *NewRelicExporter) appendMetrics(metrics []NewRelicMetricDescriptor, values StatValues, timestamp uint64, attrs map[string]string) []NewRelicMetricDescriptor {
+	for _, name := range values.Names() {
+		if !n.opts.exports(name) {
+			continue
+		}
+
 		md, err := getNewRelicMetric(name)
 		if err != nil {
-			if err == ErrNotFound {
-				// If we haven't defined the metric, just ignore it
-				continue
+			// A field without a catalog entry, exported as is
+			md = NewRelicMetricDescriptor{
+				Type:       NRGauge,
+				Attributes: map[string]string{"system": "fastly"},
 			}
-			continue
 		}
 
 		md.Name = fmt.Sprintf("fastly.%s", name)
-		md.Value = v.Field(i).Interface()
-		md.Timestamp = int64(s.IntervalStart)
-		metrics[0].Metrics = append(metrics[0].Metrics, md)
+		md.Value = values[name]
+		if isIntegerField(name) && !n.opts.RatesAsDouble {
+			md.Value = uint64(math.Round(values[name]))
+		}
+		md.Timestamp = int64(timestamp)
+		md.Attributes = withAttributes(md.Attributes, attrs)
+		metrics = append(metrics, md)
 	}
 
 	return metrics
 }
 
-func (n *NewRelicExporter) report(ctx context.Context, r []NewRelicMetricReport) error {
+// reportWithRetry reports r, retrying transient failures with backoff until
+// the retry deadline has passed.
+func (n *NewRelicExporter) reportWithRetry(ctx context.Context, r []NewRelicMetricReport) error {
+	ll := zap.S()
+
 	body := bytes.NewBuffer(nil)
 	if err := json.NewEncoder(body).Encode(r); err != nil {
 		return err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, nrEndpoint, body)
+	deadline := time.Now().Add(n.retryDeadline)
+	b := backoff{initial: time.Second, max: 30 * time.Second}
+	for attempt := 1; ; attempt++ {
+		err := n.report(ctx, body.Bytes())
+		if err == nil {
+			return nil
+		}
+
+		retryable, delay := isRetryable(err)
+		if !retryable {
+			return err
+		}
+		if delay == 0 {
+			delay = b.next()
+		}
+
+		if time.Now().Add(delay).After(deadline) {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+
+		ll.Warnf("failed to report to New Relic (attempt %d), retrying in %v: %v", attempt, delay, err)
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+func (n *NewRelicExporter) report(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, nrEndpoint, bytes.NewReader(body))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -91,25 +286,46 @@ func (n *NewRelicExporter) report(ctx context.Context, r []NewRelicMetricReport)
 
 	resp, err := n.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to execute http request: %w", err)
+		if ctx.Err() != nil {
+			return err
+		}
+		return &retryableError{err: fmt.Errorf("failed to execute http request: %w", err)}
 	}
 	defer resp.Body.Close()
 	defer io.Copy(io.Discard, resp.Body)
 
-	if resp.StatusCode != http.StatusAccepted {
+	switch {
+	case resp.StatusCode == http.StatusAccepted:
+		return nil
+	case resp.StatusCode == http.StatusRequestTimeout,
+		resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode >= 500:
+		return &retryableError{
+			err:        fmt.Errorf("invalid response status '%s'", resp.Status),
+			retryAfter: parseRetryAfter(resp.Header),
+		}
+	default:
+		// 400, 403, 413 and the like will fail the same way on every attempt
 		return fmt.Errorf("invalid response status '%s'", resp.Status)
 	}
+}
 
-	return nil
+// AcceptsRaw implements RawAccepter.
+func (n *NewRelicExporter) AcceptsRaw() bool {
+	return true
 }
 
 func (n *NewRelicExporter) Run(ctx context.Context) {
 	l := zap.S()
 	for {
 		select {
-		case s := <-n.ch:
+		case s, ok := <-n.ch:
+			if !ok {
+				l.Infof("channel closed, not exporting any more stats")
+				return
+			}
 			report := n.buildMetrics(s)
-			if err := n.report(ctx, report); err != nil {
+			if err := n.reportWithRetry(ctx, report); err != nil {
 				l.Errorf("failed to report to New Relic: %v", err)
 				continue
 			}
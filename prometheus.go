@@ -0,0 +1,300 @@
+package fastlystats
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// prometheusContentType is the content type of the text exposition format.
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+func init() {
+	RegisterExporter("prometheus", func(cfg *Config, ch <-chan *FastlyMeanStats) (Exporter, error) {
+		if cfg.PrometheusListenAddr == "" {
+			return nil, ErrSinkDisabled
+		}
+		opts, err := cfg.ExportOptions("prometheus")
+		if err != nil {
+			return nil, err
+		}
+		return NewPrometheusExporter(cfg.PrometheusListenAddr, opts, ch)
+	})
+}
+
+// PrometheusExporter keeps the latest stats of every service and serves them
+// on /metrics in the Prometheus text exposition format.
+type PrometheusExporter struct {
+	listenAddr string
+	opts       ExportOptions
+	ch         <-chan *FastlyMeanStats
+
+	mu     sync.RWMutex
+	latest map[string]*FastlyMeanStats
+}
+
+func NewPrometheusExporter(listenAddr string, opts ExportOptions, ch <-chan *FastlyMeanStats) (*PrometheusExporter, error) {
+	return &PrometheusExporter{
+		listenAddr: listenAddr,
+		opts:       opts,
+		ch:         ch,
+		latest:     map[string]*FastlyMeanStats{},
+	}, nil
+}
+
+// AcceptsRaw implements RawAccepter.
+func (p *PrometheusExporter) AcceptsRaw() bool {
+	return true
+}
+
+func (p *PrometheusExporter) Run(ctx context.Context) {
+	ll := zap.S()
+	ll.Infof("starting prometheus exporter on %s", p.listenAddr)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", p)
+
+	server := &http.Server{
+		Addr:              p.listenAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	// Without a server nothing is exported, so the exporter stops with it
+	failed := make(chan struct{})
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			ll.Errorf("prometheus server failed: %v", err)
+			close(failed)
+		}
+	}()
+
+	for {
+		select {
+		case <-failed:
+			return
+		case s, ok := <-p.ch:
+			if !ok {
+				ll.Infof("channel closed, not exporting any more stats")
+				return
+			}
+			p.mu.Lock()
+			p.latest[s.Service] = s
+			p.mu.Unlock()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// ServeHTTP writes the latest value of every fastly.Stats field.
+func (p *PrometheusExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.RLock()
+	services := make([]string, 0, len(p.latest))
+	for service := range p.latest {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+
+	snapshots := make([]*FastlyMeanStats, 0, len(services))
+	for _, service := range services {
+		snapshots = append(snapshots, p.latest[service])
+	}
+	p.mu.RUnlock()
+
+	w.Header().Set("Content-Type", prometheusContentType)
+
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+
+	seen := map[string]bool{}
+	var names []string
+	for _, s := range snapshots {
+		for name := range s.Values {
+			if !seen[name] && p.opts.exports(name) {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		metricName := fmt.Sprintf("fastly_%s", name)
+
+		help := name
+		if md, err := getMetricDescriptor(name); err == nil {
+			help = fmt.Sprintf("%s (unit: %s)", md.Description, md.Unit)
+		}
+
+		fmt.Fprintf(bw, "# HELP %s %s\n", metricName, escapePrometheusHelp(help))
+		fmt.Fprintf(bw, "# TYPE %s gauge\n", metricName)
+
+		for _, s := range snapshots {
+			p.writeSample(bw, metricName, s.Values, name, [][2]string{{"service", s.Service}})
+
+			pops := make([]string, 0, len(s.DatacenterValues))
+			for pop := range s.DatacenterValues {
+				pops = append(pops, pop)
+			}
+			sort.Strings(pops)
+
+			for _, pop := range pops {
+				p.writeSample(bw, metricName, s.DatacenterValues[pop], name, [][2]string{{"service", s.Service}, {"pop", pop}})
+			}
+		}
+	}
+
+	writePrometheusDistributions(bw, snapshots)
+	writePrometheusMissHistogram(bw, snapshots)
+	writePrometheusFeed(bw, snapshots)
+}
+
+// writePrometheusFeed writes gauges describing the realtime feed itself. The
+// lag is measured at scrape time, so that it keeps growing when the feed
+// stalls and no snapshots arrive.
+func writePrometheusFeed(w *bufio.Writer, snapshots []*FastlyMeanStats) {
+	now := time.Now()
+
+	for _, m := range []struct {
+		name  string
+		help  string
+		value func(s *FastlyMeanStats) float64
+	}{
+		{"fastly_data_lag_seconds", "Time since the last second of the latest stats", func(s *FastlyMeanStats) float64 {
+			return now.Sub(time.Unix(int64(s.IntervalEnd)+1, 0)).Seconds()
+		}},
+		{"fastly_sample_count", "Number of seconds with realtime data in the latest interval", func(s *FastlyMeanStats) float64 {
+			return float64(s.SampleCount)
+		}},
+		{"fastly_missing_seconds", "Number of seconds without realtime data in the latest interval and since the previous one", func(s *FastlyMeanStats) float64 {
+			return float64(s.MissingSeconds)
+		}},
+	} {
+		fmt.Fprintf(w, "# HELP %s %s\n", m.name, m.help)
+		fmt.Fprintf(w, "# TYPE %s gauge\n", m.name)
+
+		for _, s := range snapshots {
+			fmt.Fprintf(w, "%s{service=\"%s\"} %s\n", m.name, escapePrometheusLabel(s.Service), strconv.FormatFloat(m.value(s), 'g', -1, 64))
+		}
+	}
+}
+
+// writePrometheusMissHistogram writes the miss latency as a histogram. The
+// buckets are cumulative in Prometheus, unlike the Fastly histogram.
+func writePrometheusMissHistogram(w *bufio.Writer, snapshots []*FastlyMeanStats) {
+	const metricName = "fastly_miss_latency_ms"
+
+	fmt.Fprintf(w, "# HELP %s Time to first byte of origin fetches on cache misses (unit: ms)\n", metricName)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", metricName)
+
+	for _, s := range snapshots {
+		h := s.MissHistogram
+		if h == nil {
+			continue
+		}
+
+		service := escapePrometheusLabel(s.Service)
+		var cumulative uint64
+		for i, c := range h.Counts {
+			cumulative += c
+			fmt.Fprintf(w, "%s_bucket{service=\"%s\",le=\"%s\"} %d\n", metricName, service, strconv.FormatFloat(h.Bounds[i], 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket{service=\"%s\",le=\"+Inf\"} %d\n", metricName, service, h.Total)
+		fmt.Fprintf(w, "%s_sum{service=\"%s\"} %s\n", metricName, service, strconv.FormatFloat(h.Mean()*float64(h.Total), 'g', -1, 64))
+		fmt.Fprintf(w, "%s_count{service=\"%s\"} %d\n", metricName, service, h.Total)
+	}
+}
+
+// writePrometheusDistributions writes the distributions of the per-second
+// values as summaries.
+func writePrometheusDistributions(w *bufio.Writer, snapshots []*FastlyMeanStats) {
+	seen := map[string]bool{}
+	var names []string
+	for _, s := range snapshots {
+		for name := range s.Distributions {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		metricName := fmt.Sprintf("fastly_%s_distribution", name)
+
+		help := fmt.Sprintf("Distribution of the per-second values of %s within each interval", name)
+		if md, err := getMetricDescriptor(name); err == nil {
+			help = fmt.Sprintf("Distribution of the per-second values within each interval. %s (unit: %s)", md.Description, md.Unit)
+		}
+
+		fmt.Fprintf(w, "# HELP %s %s\n", metricName, escapePrometheusHelp(help))
+		fmt.Fprintf(w, "# TYPE %s summary\n", metricName)
+
+		for _, s := range snapshots {
+			d, ok := s.Distributions[name]
+			if !ok {
+				continue
+			}
+
+			service := escapePrometheusLabel(s.Service)
+			for _, q := range []struct {
+				quantile string
+				value    float64
+			}{{"0", d.Min}, {"0.5", d.P50}, {"0.9", d.P90}, {"0.95", d.P95}, {"0.99", d.P99}, {"1", d.Max}} {
+				fmt.Fprintf(w, "%s{service=\"%s\",quantile=\"%s\"} %s\n", metricName, service, q.quantile, strconv.FormatFloat(q.value, 'g', -1, 64))
+			}
+			fmt.Fprintf(w, "%s_sum{service=\"%s\"} %s\n", metricName, service, strconv.FormatFloat(d.Sum, 'g', -1, 64))
+			fmt.Fprintf(w, "%s_count{service=\"%s\"} %d\n", metricName, service, d.Count)
+		}
+	}
+}
+
+func (p *PrometheusExporter) writeSample(w *bufio.Writer, metricName string, values StatValues, name string, labels [][2]string) {
+	v, ok := values[name]
+	if !ok {
+		return
+	}
+
+	value := strconv.FormatFloat(v, 'g', -1, 64)
+	if isIntegerField(name) && !p.opts.RatesAsDouble {
+		value = strconv.FormatUint(uint64(math.Round(v)), 10)
+	}
+
+	pairs := make([]string, 0, len(labels))
+	for _, l := range labels {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", l[0], escapePrometheusLabel(l[1])))
+	}
+
+	fmt.Fprintf(w, "%s{%s} %s\n", metricName, strings.Join(pairs, ","), value)
+}
+
+var prometheusHelpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapePrometheusHelp(s string) string {
+	return prometheusHelpEscaper.Replace(s)
+}
+
+func escapePrometheusLabel(s string) string {
+	return prometheusLabelEscaper.Replace(s)
+}
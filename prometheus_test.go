@@ -0,0 +1,33 @@
+package fastlystats
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPrometheusExporterServerFails(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	ch := make(chan *FastlyMeanStats)
+	p, err := NewPrometheusExporter(l.Addr().String(), ExportOptions{}, ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		p.Run(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("exporter kept running without a server")
+	}
+}
@@ -0,0 +1,181 @@
+package fastlystats
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/fastly/go-fastly/v3/fastly"
+	"github.com/mitchellh/mapstructure"
+	"go.uber.org/zap"
+)
+
+// realtimeSample is one second of realtime stats. Values and Datacenters
+// hold its numeric fields keyed by name, aggregated over all POPs and per
+// POP. When all fields are decoded they include fields fastly.Stats does not
+// know, otherwise only those it does.
+type realtimeSample struct {
+	*fastly.RealtimeData
+	Values      StatValues
+	Datacenters map[string]StatValues
+}
+
+// statFields indexes the numeric fields of fastly.Stats by metric name.
+var statFields = func() map[string]reflect.StructField {
+	t := reflect.TypeOf(fastly.Stats{})
+	fields := make(map[string]reflect.StructField, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		switch f.Type.Kind() {
+		case reflect.Uint64, reflect.Float64:
+			fields[f.Tag.Get("mapstructure")] = f
+		}
+	}
+
+	return fields
+}()
+
+// isIntegerField reports whether name is an integer field of fastly.Stats.
+// Fields it does not know are taken as doubles.
+func isIntegerField(name string) bool {
+	f, ok := statFields[name]
+	return ok && f.Type.Kind() == reflect.Uint64
+}
+
+// Names returns the names of all values, sorted.
+func (v StatValues) Names() []string {
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// statValuesOf returns the numeric fields of stats keyed by metric name.
+func statValuesOf(stats *fastly.Stats) StatValues {
+	values := make(StatValues, len(statFields))
+	v := reflect.ValueOf(stats).Elem()
+	for name, f := range statFields {
+		if f.Type.Kind() == reflect.Uint64 {
+			values[name] = float64(v.FieldByIndex(f.Index).Uint())
+		} else {
+			values[name] = v.FieldByIndex(f.Index).Float()
+		}
+	}
+
+	return values
+}
+
+// statsOf returns values as fastly.Stats, with integer fields rounded.
+// Values it has no field for are left out.
+func statsOf(values StatValues) *fastly.Stats {
+	stats := &fastly.Stats{}
+	v := reflect.ValueOf(stats).Elem()
+	for name, f := range statFields {
+		if f.Type.Kind() == reflect.Uint64 {
+			v.FieldByIndex(f.Index).SetUint(uint64(math.Round(values[name])))
+		} else {
+			v.FieldByIndex(f.Index).SetFloat(values[name])
+		}
+	}
+
+	return stats
+}
+
+// numericFields returns the numbers in a decoded JSON object. Anything else,
+// such as the nested miss histogram, is left out.
+func numericFields(obj interface{}) StatValues {
+	m, _ := obj.(map[string]interface{})
+
+	values := make(StatValues, len(m))
+	for name, v := range m {
+		if f, ok := v.(float64); ok {
+			values[name] = f
+		}
+	}
+
+	return values
+}
+
+// decodeRealtimeStats decodes a realtime stats response. With allFields,
+// the values of every sample are read from the JSON rather than from
+// fastly.Stats, so that fields added by Fastly are kept.
+func decodeRealtimeStats(data map[string]interface{}, allFields bool) (*fastly.RealtimeStatsResponse, []*realtimeSample, error) {
+	var resp fastly.RealtimeStatsResponse
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		WeaklyTypedInput: true,
+		Result:           &resp,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := decoder.Decode(data); err != nil {
+		return nil, nil, err
+	}
+
+	raw, _ := data["Data"].([]interface{})
+
+	samples := make([]*realtimeSample, 0, len(resp.Data))
+	for i, rtdata := range resp.Data {
+		if rtdata.Aggregated == nil {
+			rtdata.Aggregated = &fastly.Stats{}
+		}
+
+		var obj map[string]interface{}
+		if i < len(raw) {
+			obj, _ = raw[i].(map[string]interface{})
+		}
+		aggregated := numericFields(obj["aggregated"])
+		reportUnknownFields(aggregated, allFields)
+
+		sample := &realtimeSample{
+			RealtimeData: rtdata,
+			Values:       statValuesOf(rtdata.Aggregated),
+			Datacenters:  make(map[string]StatValues, len(rtdata.Datacenter)),
+		}
+		for pop, stats := range rtdata.Datacenter {
+			sample.Datacenters[pop] = statValuesOf(stats)
+		}
+
+		if allFields {
+			sample.Values = aggregated
+			datacenters, _ := obj["datacenter"].(map[string]interface{})
+			for pop, stats := range datacenters {
+				sample.Datacenters[pop] = numericFields(stats)
+			}
+		}
+
+		samples = append(samples, sample)
+	}
+
+	return &resp, samples, nil
+}
+
+// reportedFields are the unknown fields that have been logged already.
+var reportedFields sync.Map
+
+// reportUnknownFields logs every field without a catalog entry the first
+// time it is seen, so that it can be added.
+func reportUnknownFields(values StatValues, allFields bool) {
+	for name := range values {
+		if _, err := getCatalogEntry(name); err == nil {
+			continue
+		}
+		if _, seen := reportedFields.LoadOrStore(name, true); seen {
+			continue
+		}
+
+		_, known := statFields[name]
+		switch {
+		case known:
+			zap.S().Infof("fastly field '%s' has no catalog entry", name)
+		case allFields:
+			zap.S().Infof("fastly field '%s' is unknown, exporting it as a double gauge", name)
+		default:
+			zap.S().Infof("fastly field '%s' is unknown and ignored, set FASTLY_ALL_FIELDS to export it", name)
+		}
+	}
+}
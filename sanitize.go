@@ -0,0 +1,238 @@
+package fastlystats
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// SanitizePolicy decides what happens to a value that cannot be exported as
+// it is.
+type SanitizePolicy string
+
+const (
+	// SanitizeDrop leaves the value out of the snapshot.
+	SanitizeDrop = SanitizePolicy("drop")
+	// SanitizeClamp replaces the value with the closest valid one: NaN
+	// becomes 0, and anything else the nearest bound.
+	SanitizeClamp = SanitizePolicy("clamp")
+)
+
+func ParseSanitizePolicy(s string) (SanitizePolicy, error) {
+	switch p := SanitizePolicy(s); p {
+	case SanitizeDrop, SanitizeClamp:
+		return p, nil
+	}
+
+	return "", fmt.Errorf("invalid sanitize policy %q, must be %s or %s", s, SanitizeDrop, SanitizeClamp)
+}
+
+// RejectReason is why a value was rejected.
+type RejectReason string
+
+const (
+	RejectNaN      = RejectReason("nan")
+	RejectInf      = RejectReason("inf")
+	RejectOverflow = RejectReason("int64_overflow")
+	// RejectNegative is a negative value of a Fastly field, all of which
+	// count or measure something.
+	RejectNegative = RejectReason("negative")
+	// RejectRange is a ratio above 1.
+	RejectRange = RejectReason("out_of_range")
+)
+
+// Rejection identifies the values rejected for one reason in one field.
+type Rejection struct {
+	Field  string
+	Reason RejectReason
+}
+
+// ratioFields are the Fastly fields that are ratios between 0 and 1.
+var ratioFields = map[string]bool{
+	"hit_ratio": true,
+}
+
+// Sanitizer checks every snapshot read from its input before passing it on,
+// so that no sink receives values it cannot export. NaN and infinite values,
+// integers that do not fit an int64, and implausible values of Fastly fields
+// are dropped or clamped as the policy says.
+type Sanitizer struct {
+	in     <-chan *FastlyMeanStats
+	out    chan<- *FastlyMeanStats
+	policy SanitizePolicy
+
+	mu       sync.Mutex
+	rejected map[Rejection]uint64
+}
+
+func NewSanitizer(policy SanitizePolicy, in <-chan *FastlyMeanStats, out chan<- *FastlyMeanStats) *Sanitizer {
+	return &Sanitizer{
+		in:       in,
+		out:      out,
+		policy:   policy,
+		rejected: map[Rejection]uint64{},
+	}
+}
+
+// Rejected returns the number of values rejected so far, keyed by field and
+// reason.
+func (s *Sanitizer) Rejected() map[string]map[RejectReason]uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rejected := map[string]map[RejectReason]uint64{}
+	for r, n := range s.rejected {
+		if rejected[r.Field] == nil {
+			rejected[r.Field] = map[RejectReason]uint64{}
+		}
+		rejected[r.Field][r.Reason] = n
+	}
+
+	return rejected
+}
+
+// Run passes on snapshots until the context is done. When the input is
+// closed, so is the output.
+func (s *Sanitizer) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case stats, ok := <-s.in:
+			if !ok {
+				close(s.out)
+				return
+			}
+
+			s.sanitize(stats)
+
+			select {
+			case s.out <- stats:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// sanitize fixes the values of stats in place.
+func (s *Sanitizer) sanitize(stats *FastlyMeanStats) {
+	s.sanitizeValues(stats.Service, stats.Values)
+	s.sanitizeValues(stats.Service, stats.Totals)
+	if stats.Values != nil {
+		stats.Stats = statsOf(stats.Values)
+	}
+
+	for pop, values := range stats.DatacenterValues {
+		s.sanitizeValues(stats.Service, values)
+		if stats.Datacenters != nil {
+			stats.Datacenters[pop] = statsOf(values)
+		}
+	}
+	for _, totals := range stats.DatacenterTotals {
+		s.sanitizeValues(stats.Service, totals)
+	}
+
+	for name, d := range stats.Distributions {
+		values := append([]float64{d.Sum, d.Mean, d.Min, d.Max, d.StdDev, d.SumOfSquaredDeviation, d.P50, d.P90, d.P95, d.P99}, d.Samples...)
+		for _, v := range values {
+			if reason, bad := checkFinite(v); bad {
+				// A distribution cannot be clamped, it is always dropped
+				s.reject(stats.Service, name+"_distribution", reason)
+				delete(stats.Distributions, name)
+				break
+			}
+		}
+	}
+}
+
+func (s *Sanitizer) sanitizeValues(service string, values StatValues) {
+	for name, v := range values {
+		reason, bad := checkValue(name, v)
+		if !bad {
+			continue
+		}
+
+		s.reject(service, name, reason)
+		if s.policy == SanitizeClamp {
+			values[name] = clampValue(name, v)
+		} else {
+			delete(values, name)
+		}
+	}
+}
+
+func (s *Sanitizer) reject(service, field string, reason RejectReason) {
+	r := Rejection{Field: field, Reason: reason}
+
+	s.mu.Lock()
+	s.rejected[r]++
+	n := s.rejected[r]
+	s.mu.Unlock()
+
+	ll := zap.S().With("service", service)
+	if n == 1 {
+		ll.Warnf("rejected value of %s (%s, policy %s), further rejections are logged at debug level", field, reason, s.policy)
+	} else {
+		ll.Debugf("rejected value of %s (%s, policy %s, %d rejected in total)", field, reason, s.policy, n)
+	}
+}
+
+func checkFinite(v float64) (RejectReason, bool) {
+	switch {
+	case math.IsNaN(v):
+		return RejectNaN, true
+	case math.IsInf(v, 0):
+		return RejectInf, true
+	}
+
+	return "", false
+}
+
+// checkValue returns why v cannot be exported as the value of the field
+// name, if it cannot.
+func checkValue(name string, v float64) (RejectReason, bool) {
+	if reason, bad := checkFinite(v); bad {
+		return reason, true
+	}
+
+	// Integer fields are rounded and exported as int64
+	if isIntegerField(name) && math.Round(v) >= math.MaxInt64 {
+		return RejectOverflow, true
+	}
+
+	if _, native := statFields[name]; native && v < 0 {
+		return RejectNegative, true
+	}
+	if ratioFields[name] && v > 1 {
+		return RejectRange, true
+	}
+
+	return "", false
+}
+
+// clampValue returns the valid value closest to v.
+func clampValue(name string, v float64) float64 {
+	_, native := statFields[name]
+
+	switch {
+	case math.IsNaN(v):
+		return 0
+	case v < 0 && native:
+		return 0
+	case ratioFields[name] && v > 1:
+		return 1
+	case isIntegerField(name) && v > 0:
+		// The largest float64 that does not exceed math.MaxInt64
+		return math.Nextafter(math.MaxInt64, 0)
+	case math.IsInf(v, 1):
+		return math.MaxFloat64
+	case math.IsInf(v, -1):
+		return -math.MaxFloat64
+	}
+
+	return v
+}
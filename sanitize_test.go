@@ -0,0 +1,108 @@
+package fastlystats
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCheckValue(t *testing.T) {
+	maxSafe := math.Nextafter(math.MaxInt64, 0)
+
+	for _, tt := range []struct {
+		name   string
+		field  string
+		value  float64
+		reason RejectReason
+	}{
+		{"valid", "requests", 10, ""},
+		{"zero", "requests", 0, ""},
+		{"nan", "hit_ratio", math.NaN(), RejectNaN},
+		{"inf", "requests", math.Inf(1), RejectInf},
+		{"negative inf", "hits_time", math.Inf(-1), RejectInf},
+		{"largest int64 float", "requests", maxSafe, ""},
+		{"int64 bound", "requests", math.MaxInt64, RejectOverflow},
+		{"beyond int64", "requests", 1e19, RejectOverflow},
+		{"rounds to bound", "requests", math.MaxInt64 - 0.4, RejectOverflow},
+		{"large double field", "hits_time", 1e19, ""},
+		{"large unknown field", "custom", 1e19, ""},
+		{"negative", "errors", -1, RejectNegative},
+		{"negative double field", "hits_time", -0.5, RejectNegative},
+		{"negative unknown field", "custom", -1, ""},
+		{"ratio", "hit_ratio", 0.9, ""},
+		{"ratio of 1", "hit_ratio", 1, ""},
+		{"ratio above 1", "hit_ratio", 1.5, RejectRange},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, bad := checkValue(tt.field, tt.value)
+			if bad != (tt.reason != "") || reason != tt.reason {
+				t.Errorf("got %q (%v), want %q", reason, bad, tt.reason)
+			}
+		})
+	}
+}
+
+func TestClampValue(t *testing.T) {
+	maxSafe := math.Nextafter(math.MaxInt64, 0)
+
+	for _, tt := range []struct {
+		name  string
+		field string
+		value float64
+		want  float64
+	}{
+		{"nan", "hit_ratio", math.NaN(), 0},
+		{"int64 bound", "requests", math.MaxInt64, maxSafe},
+		{"beyond int64", "requests", 1e19, maxSafe},
+		{"inf integer", "requests", math.Inf(1), maxSafe},
+		{"negative inf integer", "requests", math.Inf(-1), 0},
+		{"inf double", "hits_time", math.Inf(1), math.MaxFloat64},
+		{"negative", "errors", -3, 0},
+		{"ratio above 1", "hit_ratio", 1.5, 1},
+		{"inf unknown", "custom", math.Inf(1), math.MaxFloat64},
+		{"negative inf unknown", "custom", math.Inf(-1), -math.MaxFloat64},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := clampValue(tt.field, tt.value)
+			if got != tt.want {
+				t.Fatalf("got %g, want %g", got, tt.want)
+			}
+			if reason, bad := checkValue(tt.field, got); bad {
+				t.Errorf("clamped value %g is rejected: %s", got, reason)
+			}
+			if isIntegerField(tt.field) {
+				if i := int64(math.Round(got)); i < 0 {
+					t.Errorf("clamped value %g overflows int64: %d", got, i)
+				}
+			}
+		})
+	}
+}
+
+func TestSanitizerRejected(t *testing.T) {
+	s := NewSanitizer(SanitizeDrop, nil, nil)
+	for i := 0; i < 3; i++ {
+		stats := &FastlyMeanStats{
+			Values: StatValues{"hit_ratio": math.NaN(), "requests": 1e19, "hits": 1},
+			Totals: StatValues{"hits": 15},
+		}
+		s.sanitize(stats)
+
+		if _, ok := stats.Values["hit_ratio"]; ok {
+			t.Errorf("hit_ratio was not dropped")
+		}
+		if stats.Values["hits"] != 1 || stats.Stats.Hits != 1 {
+			t.Errorf("hits changed: %v", stats.Values["hits"])
+		}
+	}
+
+	rejected := s.Rejected()
+	if got := rejected["hit_ratio"][RejectNaN]; got != 3 {
+		t.Errorf("hit_ratio nan: got %d rejections, want 3", got)
+	}
+	if got := rejected["requests"][RejectOverflow]; got != 3 {
+		t.Errorf("requests overflow: got %d rejections, want 3", got)
+	}
+	if _, ok := rejected["hits"]; ok {
+		t.Errorf("hits was rejected")
+	}
+}
@@ -3,17 +3,24 @@ package fastlystats
 import (
 	"context"
 	"fmt"
+	"math"
 	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	monitoring "cloud.google.com/go/monitoring/apiv3"
 	"go.uber.org/zap"
+	"google.golang.org/api/iterator"
 	"google.golang.org/genproto/googleapis/api/metric"
 	"google.golang.org/genproto/googleapis/api/monitoredres"
 	monitoringpb "google.golang.org/genproto/googleapis/monitoring/v3"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -29,24 +36,161 @@ const timeSeriesBatchSize = 200
 // entries before sending a batch with fewer time series.
 const maxReportTimeout = 2 * time.Second
 
+// minPointInterval is the quota for writing points to the same time series.
+// See https://cloud.google.com/monitoring/quotas
+// At the time of writing this is 1 point per 10 seconds.
+const minPointInterval = 10 * time.Second
+
+// maxPointAge is how old a point may be when it is written. The API rejects
+// points older than 25 hours, this leaves a margin for batching and retries.
+const maxPointAge = 24 * time.Hour
+
+// maxReportAttempts is the number of times a batch is sent before giving up,
+// when the failure is transient.
+const maxReportAttempts = 5
+
+func init() {
+	RegisterExporter("stackdriver", func(cfg *Config, ch <-chan *FastlyMeanStats) (Exporter, error) {
+		if cfg.GoogleCloudProject == "" {
+			return nil, ErrSinkDisabled
+		}
+		opts, err := cfg.ExportOptions("stackdriver")
+		if err != nil {
+			return nil, err
+		}
+		return NewStackdriverExporter(cfg.GoogleCloudProject, opts, ch)
+	})
+}
+
 type StackdriverExporter struct {
 	metricClient *monitoring.MetricClient
 
 	ch                 <-chan *FastlyMeanStats
 	timeSeriesCh       chan *monitoringpb.TimeSeries
 	googleCloudProject string
-	nodeID             string
+	opts               ExportOptions
+
+	// cumulative holds the running totals of CUMULATIVE series. It is only
+	// used by the time series worker.
+	cumulative map[string]*cumulativeSeries
+}
+
+func getMetricDescriptor(name string) (*metric.MetricDescriptor, error) {
+	for _, md := range MetricDescriptors {
+		if md.Name == name {
+			return md, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+// exportedDescriptor returns the descriptor of m as the exporter will write
+// it with opts.
+func exportedDescriptor(m *metric.MetricDescriptor, opts ExportOptions) *metric.MetricDescriptor {
+	m = proto.Clone(m).(*metric.MetricDescriptor)
+	m.Labels = metricLabels
+	if opts.RatesAsDouble && m.ValueType == metric.MetricDescriptor_INT64 {
+		m.ValueType = metric.MetricDescriptor_DOUBLE
+	}
+	if isCounter(m.Name) && opts.CounterKind != CounterGauge {
+		// Totals over an interval rather than rates
+		m.MetricKind = opts.counterMetricKind()
+		m.Unit = strings.TrimSuffix(m.Unit, "/s")
+	}
+
+	return m
+}
+
+// counterMetricKind is the Stackdriver metric kind of counter-like fields.
+func (o ExportOptions) counterMetricKind() metric.MetricDescriptor_MetricKind {
+	switch o.CounterKind {
+	case CounterDelta:
+		return metric.MetricDescriptor_DELTA
+	case CounterCumulative:
+		return metric.MetricDescriptor_CUMULATIVE
+	default:
+		return metric.MetricDescriptor_GAUGE
+	}
+}
+
+// checkMetricDescriptors warns about metric descriptors whose kind or value
+// type does not match what the exporter writes, as Stackdriver rejects such
+// points. They have to be rebuilt, which deletes their data, so this is left
+// to the operator.
+func (s *StackdriverExporter) checkMetricDescriptors(ctx context.Context) {
+	ll := zap.S()
+
+	it := s.metricClient.ListMetricDescriptors(ctx, &monitoringpb.ListMetricDescriptorsRequest{
+		Name:   fmt.Sprintf("projects/%s", s.googleCloudProject),
+		Filter: `metric.type = starts_with("custom.googleapis.com/fastly/")`,
+	})
+
+	existing := map[string]*metric.MetricDescriptor{}
+	for {
+		md, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			ll.Warnf("failed to list metric descriptors, not checking them: %v", err)
+			return
+		}
+		existing[md.Type] = md
+	}
+
+	wanted := make([]*metric.MetricDescriptor, 0, len(MetricDescriptors)+len(missLatencyDescriptors)+len(feedDescriptors))
+	for _, m := range MetricDescriptors {
+		if s.opts.exports(m.Name) {
+			wanted = append(wanted, exportedDescriptor(m, s.opts))
+		}
+	}
+	wanted = append(wanted, missLatencyDescriptors...)
+	wanted = append(wanted, feedDescriptors...)
+
+	var mismatched []string
+	for _, want := range wanted {
+		got, ok := existing[want.Type]
+		if !ok {
+			continue
+		}
+		if got.MetricKind != want.MetricKind || got.ValueType != want.ValueType {
+			mismatched = append(mismatched, fmt.Sprintf("%s (%v %v, want %v %v)", want.Name, got.MetricKind, got.ValueType, want.MetricKind, want.ValueType))
+		}
+	}
+
+	if len(mismatched) > 0 {
+		ll.Errorf("%d metric descriptors do not match the configured export and their points will be rejected, "+
+			"rebuild them with -rebuild-metric-descriptors: %s", len(mismatched), strings.Join(mismatched, ", "))
+	}
 }
 
-func SetupMetricDescriptors(ctx context.Context, googleCloudProject string) {
+func SetupMetricDescriptors(ctx context.Context, googleCloudProject string, opts ExportOptions) {
 	ll := zap.S()
 	metricClient, err := monitoring.NewMetricClient(ctx)
 	if err != nil {
 		ll.Fatal(err)
 	}
 
-	ll.Infof("Setting up metric descriptors")
+	descriptors := make([]*metric.MetricDescriptor, 0, len(MetricDescriptors)+len(opts.DistributionFields))
 	for _, m := range MetricDescriptors {
+		if opts.exports(m.Name) {
+			descriptors = append(descriptors, exportedDescriptor(m, opts))
+		}
+	}
+	descriptors = append(descriptors, missLatencyDescriptors...)
+	descriptors = append(descriptors, feedDescriptors...)
+	for _, name := range opts.DistributionFields {
+		m, err := getMetricDescriptor(name)
+		if err != nil {
+			ll.Warnf("No metric descriptor for distribution field '%s', skipping it", name)
+			continue
+		}
+		descriptors = append(descriptors, distributionDescriptor(m))
+	}
+
+	ll.Infof("Setting up metric descriptors")
+	for _, m := range descriptors {
 		name := fmt.Sprintf("projects/%s/metricDescriptors/%s", googleCloudProject, m.Type)
 
 		ll.Infof("Recreating metric '%s'", m.Type)
@@ -67,7 +211,7 @@ func SetupMetricDescriptors(ctx context.Context, googleCloudProject string) {
 	}
 }
 
-func NewStackdriverExporter(project string, nodeID string, ch <-chan *FastlyMeanStats) (*StackdriverExporter, error) {
+func NewStackdriverExporter(project string, opts ExportOptions, ch <-chan *FastlyMeanStats) (*StackdriverExporter, error) {
 	metricClient, err := monitoring.NewMetricClient(context.Background())
 	if err != nil {
 		return nil, err
@@ -78,22 +222,31 @@ func NewStackdriverExporter(project string, nodeID string, ch <-chan *FastlyMean
 		ch:                 ch,
 		timeSeriesCh:       make(chan *monitoringpb.TimeSeries, timeSeriesBatchSize),
 		googleCloudProject: project,
-		nodeID:             nodeID,
+		opts:               opts,
+		cumulative:         map[string]*cumulativeSeries{},
 	}, nil
 }
 
+// MinInterval implements IntervalLimiter.
+func (s *StackdriverExporter) MinInterval() time.Duration {
+	return minPointInterval
+}
+
 func (s *StackdriverExporter) Run(ctx context.Context) {
 	ll := zap.S()
 	ll.Infof("starting stackdriver exporter to project %s", s.googleCloudProject)
 
+	s.checkMetricDescriptors(ctx)
+
 	tasks := 2
 	wg := sync.WaitGroup{}
 	wg.Add(tasks)
 
 	ictx, cancel := context.WithCancel(ctx)
 
+	// The worker closes timeSeriesCh when its input is closed, so the
+	// reporter can flush what is left before exiting.
 	go func() {
-		defer cancel()
 		defer wg.Done()
 		s.timeSeriesWorker(ictx)
 	}()
@@ -107,44 +260,95 @@ func (s *StackdriverExporter) Run(ctx context.Context) {
 	wg.Wait()
 }
 
-func (s *StackdriverExporter) timeSeries(stats *FastlyMeanStats) []*monitoringpb.TimeSeries {
+// cumulativeSeries is the running total of a CUMULATIVE time series.
+type cumulativeSeries struct {
+	start time.Time
+	total float64
+}
+
+// timeSeries builds the time series of every field of meanStats, for the
+// stats aggregated over all POPs or, if pop is set, for that POP.
+func (s *StackdriverExporter) timeSeries(meanStats *FastlyMeanStats, pop string) []*monitoringpb.TimeSeries {
 	var result []*monitoringpb.TimeSeries
 
-	t := reflect.TypeOf(*stats.Stats)
-	v := reflect.ValueOf(*stats.Stats)
+	values, totals := meanStats.Values, meanStats.Totals
+	var labels map[string]string
+	if pop != "" {
+		values, totals = meanStats.DatacenterValues[pop], meanStats.DatacenterTotals[pop]
+		labels = map[string]string{"pop": pop}
+	}
 
-	for i := 0; i < t.NumField(); i++ {
-		field := t.Field(i)
-		metricName := field.Tag.Get("mapstructure")
+	// Every sample covers one second, so the interval ends one second
+	// after the last one was recorded. The previous interval ended where
+	// this one begins, and Stackdriver wants the start at least 1ms after
+	// that.
+	start := time.Unix(int64(meanStats.IntervalStart), 0).Add(time.Millisecond)
+	end := time.Unix(int64(meanStats.IntervalEnd)+1, 0)
+
+	for _, metricName := range values.Names() {
+		if !s.opts.exports(metricName) {
+			continue
+		}
 
 		metricKind := metric.MetricDescriptor_GAUGE
+		if isCounter(metricName) {
+			metricKind = s.opts.counterMetricKind()
+		}
 
-		valueType := metric.MetricDescriptor_VALUE_TYPE_UNSPECIFIED
-		switch t.Field(i).Type.Kind() {
-		case reflect.Uint64:
+		// Fields unknown to fastly.Stats are written as doubles
+		valueType := metric.MetricDescriptor_DOUBLE
+		getValue := doubleValuer
+		value := reflect.ValueOf(values[metricName])
+		if isIntegerField(metricName) && !s.opts.RatesAsDouble {
 			valueType = metric.MetricDescriptor_INT64
-		case reflect.Float64:
-			valueType = metric.MetricDescriptor_DOUBLE
+			getValue = int64Valuer
+			value = reflect.ValueOf(uint64(math.Round(values[metricName])))
 		}
 
-		var getValue valuer
-		switch valueType {
-		case metric.MetricDescriptor_INT64:
-			getValue = int64Valuer
-		case metric.MetricDescriptor_DOUBLE:
-			getValue = doubleValuer
-		default:
-			zap.S().Debugf("no valuer for type %v: skipping metric '%s'", valueType, metricName)
+		metricType := fmt.Sprintf("custom.googleapis.com/fastly/%s", metricName)
+
+		// StartTime is set to End Time for gauges, it's not supported that
+		// these differ (it gives an API error).
+		interval := &monitoringpb.TimeInterval{
+			StartTime: timestamppb.New(end),
+			EndTime:   timestamppb.New(end),
+		}
+
+		switch metricKind {
+		case metric.MetricDescriptor_DELTA:
+			value = counterValue(valueType, totals[metricName])
+			interval.StartTime = timestamppb.New(start)
+
+		case metric.MetricDescriptor_CUMULATIVE:
+			key := fmt.Sprintf("%s/%s/%s", meanStats.Service, metricType, pop)
+			series, ok := s.cumulative[key]
+			if !ok {
+				series = &cumulativeSeries{start: start}
+				s.cumulative[key] = series
+			}
+			series.total += totals[metricName]
+
+			value = counterValue(valueType, series.total)
+			interval.StartTime = timestamppb.New(series.start)
+		}
+
+		typedValue := getValue(value)
+		if typedValue == nil {
+			// Stackdriver would reject the whole batch
 			continue
 		}
 
 		ts := &monitoringpb.TimeSeries{
 			Metric: &metric.Metric{
-				Type: fmt.Sprintf("custom.googleapis.com/fastly/%s", metricName),
+				Type:   metricType,
+				Labels: labels,
 			},
 			MetricKind: metricKind,
 			ValueType:  valueType,
-			Points:     []*monitoringpb.Point{{Value: getValue(v.Field(i))}},
+			Points: []*monitoringpb.Point{{
+				Interval: interval,
+				Value:    typedValue,
+			}},
 		}
 
 		result = append(result, ts)
@@ -153,24 +357,62 @@ func (s *StackdriverExporter) timeSeries(stats *FastlyMeanStats) []*monitoringpb
 	return result
 }
 
+// feedTimeSeries builds the time series describing the realtime feed itself:
+// its lag, and the seconds with and without data.
+func (s *StackdriverExporter) feedTimeSeries(meanStats *FastlyMeanStats) []*monitoringpb.TimeSeries {
+	end := timestamppb.New(time.Unix(int64(meanStats.IntervalEnd)+1, 0))
+	interval := &monitoringpb.TimeInterval{
+		StartTime: end,
+		EndTime:   end,
+	}
+
+	values := []struct {
+		name      string
+		valueType metric.MetricDescriptor_ValueType
+		value     *monitoringpb.TypedValue
+	}{
+		{"data_lag", metric.MetricDescriptor_DOUBLE, &monitoringpb.TypedValue{Value: &monitoringpb.TypedValue_DoubleValue{DoubleValue: meanStats.Lag.Seconds()}}},
+		{"sample_count", metric.MetricDescriptor_INT64, &monitoringpb.TypedValue{Value: &monitoringpb.TypedValue_Int64Value{Int64Value: int64(meanStats.SampleCount)}}},
+		{"missing_seconds", metric.MetricDescriptor_INT64, &monitoringpb.TypedValue{Value: &monitoringpb.TypedValue_Int64Value{Int64Value: int64(meanStats.MissingSeconds)}}},
+	}
+
+	result := make([]*monitoringpb.TimeSeries, 0, len(values))
+	for _, v := range values {
+		result = append(result, &monitoringpb.TimeSeries{
+			Metric: &metric.Metric{
+				Type: fmt.Sprintf("custom.googleapis.com/fastly/%s", v.name),
+			},
+			MetricKind: metric.MetricDescriptor_GAUGE,
+			ValueType:  v.valueType,
+			Points: []*monitoringpb.Point{{
+				Interval: interval,
+				Value:    v.value,
+			}},
+		})
+	}
+
+	return result
+}
+
+// counterValue returns the total of a counter as a value for valuer of
+// valueType.
+func counterValue(valueType metric.MetricDescriptor_ValueType, total float64) reflect.Value {
+	if valueType == metric.MetricDescriptor_INT64 {
+		return reflect.ValueOf(uint64(math.Round(total)))
+	}
+
+	return reflect.ValueOf(total)
+}
+
 func (s *StackdriverExporter) sendTimeSeries(
 	ctx context.Context,
-	intervalStart, intervalEnd uint64,
 	resource *monitoredres.MonitoredResource,
 	timeSeries []*monitoringpb.TimeSeries,
 ) error {
-	for i, ts := range timeSeries {
+	for i := range timeSeries {
 
 		// Set some common values for all time series
 		timeSeries[i].Resource = resource
-		for j := range ts.Points {
-			timeSeries[i].Points[j].Interval = &monitoringpb.TimeInterval{
-				// StartTime is set to End Time, because it's not supported that these
-				// differ yet (it gives an API error).
-				StartTime: timestamppb.New(time.Unix(int64(intervalEnd), 0)),
-				EndTime:   timestamppb.New(time.Unix(int64(intervalEnd), 0)),
-			}
-		}
 
 		// Send it on the channel for batching and reporting
 		select {
@@ -192,20 +434,37 @@ func (s *StackdriverExporter) timeSeriesWorker(ctx context.Context) {
 		case meanStats, ok := <-s.ch:
 			if !ok {
 				ll.Infof("channel closed, not exporting any more stats")
+				close(s.timeSeriesCh)
 				return
 			}
 
+			if age := time.Since(time.Unix(int64(meanStats.IntervalEnd), 0)); age > maxPointAge {
+				ll.Warnf("skipping stats of service %s that are %v old, older than the %v stackdriver accepts",
+					meanStats.Service, age.Truncate(time.Second), maxPointAge)
+				continue
+			}
+
+			// The Fastly service is the node, so every series is kept apart per
+			// service.
 			monitoredResource := &monitoredres.MonitoredResource{
 				Type: "generic_node",
 				Labels: map[string]string{
 					"project_id": s.googleCloudProject,
 					"location":   "global",
 					"namespace":  "fastly",
-					"node_id":    s.nodeID,
+					"node_id":    meanStats.Service,
 				},
 			}
 
-			if err := s.sendTimeSeries(ctx, meanStats.IntervalStart, meanStats.IntervalEnd, monitoredResource, s.timeSeries(meanStats)); err != nil {
+			timeSeries := s.timeSeries(meanStats, "")
+			for pop := range meanStats.Datacenters {
+				timeSeries = append(timeSeries, s.timeSeries(meanStats, pop)...)
+			}
+			timeSeries = append(timeSeries, s.distributionTimeSeries(meanStats)...)
+			timeSeries = append(timeSeries, s.missHistogramTimeSeries(meanStats)...)
+			timeSeries = append(timeSeries, s.feedTimeSeries(meanStats)...)
+
+			if err := s.sendTimeSeries(ctx, monitoredResource, timeSeries); err != nil {
 				zap.S().Warnf("failed to send time series: %v", err)
 			}
 
@@ -220,6 +479,7 @@ func (s *StackdriverExporter) timeSeriesReporter(ctx context.Context) {
 	ll := zap.S().With("type", "reporter")
 
 	batch := make([]*monitoringpb.TimeSeries, 0, timeSeriesBatchSize)
+	inBatch := map[string]bool{}
 	var timeoutCh <-chan time.Time
 
 	report := func() {
@@ -227,6 +487,7 @@ func (s *StackdriverExporter) timeSeriesReporter(ctx context.Context) {
 			ll.Warnf("failed to report timeseries: %v", err)
 		}
 		batch = batch[:0]
+		inBatch = map[string]bool{}
 		timeoutCh = nil
 	}
 
@@ -238,9 +499,18 @@ func (s *StackdriverExporter) timeSeriesReporter(ctx context.Context) {
 		case ts, ok := <-s.timeSeriesCh:
 			if !ok {
 				ll.Infof("time series chan closed, exiting")
+				report()
 				return
 			}
 
+			// A request may hold only one point per time series, which happens
+			// when older stats are written in bulk
+			key := seriesKey(ts)
+			if inBatch[key] {
+				report()
+			}
+			inBatch[key] = true
+
 			batch = append(batch, ts)
 			if timeoutCh == nil {
 				timeoutCh = time.After(maxReportTimeout)
@@ -257,21 +527,179 @@ func (s *StackdriverExporter) timeSeriesReporter(ctx context.Context) {
 	}
 }
 
+// seriesKey identifies the time series a point belongs to.
+func seriesKey(ts *monitoringpb.TimeSeries) string {
+	var b strings.Builder
+	b.WriteString(ts.Metric.Type)
+	for _, labels := range []map[string]string{ts.Metric.Labels, ts.Resource.Labels} {
+		keys := make([]string, 0, len(labels))
+		for k := range labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&b, ",%s=%s", k, labels[k])
+		}
+	}
+
+	return b.String()
+}
+
+// reportBatch writes batch, retrying transient failures with backoff. When
+// the API accepted part of the batch, only the rejected time series are
+// considered for a retry so that no point is written twice.
 func (s *StackdriverExporter) reportBatch(ctx context.Context, batch []*monitoringpb.TimeSeries) error {
+	ll := zap.S()
+
 	if len(batch) == 0 {
 		return nil
 	}
 
-	start := time.Now()
-	err := s.metricClient.CreateTimeSeries(ctx, &monitoringpb.CreateTimeSeriesRequest{
-		Name:       fmt.Sprintf("projects/%s", s.googleCloudProject),
-		TimeSeries: batch,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to create %d time series: %v", len(batch), err)
+	b := backoff{initial: time.Second, max: 20 * time.Second}
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		err := s.metricClient.CreateTimeSeries(ctx, &monitoringpb.CreateTimeSeriesRequest{
+			Name:       fmt.Sprintf("projects/%s", s.googleCloudProject),
+			TimeSeries: batch,
+		})
+		if err == nil {
+			ll.Debugf("successfully reported %d time series in %v", len(batch), time.Since(start))
+			return nil
+		}
+
+		st := status.Convert(err)
+
+		// The errors refer to the series by their index in this request,
+		// before the batch is narrowed down to the rejected ones
+		errs := map[*monitoringpb.TimeSeries]string{}
+		for i, msg := range timeSeriesIndexErrors(st.Message()) {
+			if i < len(batch) {
+				errs[batch[i]] = msg
+			}
+		}
+
+		if summary := createTimeSeriesSummary(st); summary != nil && summary.SuccessPointCount > 0 {
+			rejected := rejectedTimeSeries(st, batch)
+			ll.Warnf("%d of %d points were written, %d rejected", summary.SuccessPointCount, summary.TotalPointCount, summary.TotalPointCount-summary.SuccessPointCount)
+			if len(rejected) == 0 {
+				// Without knowing which series failed, a retry would duplicate
+				// the accepted ones
+				return fmt.Errorf("failed to create some of %d time series: %v", len(batch), err)
+			}
+			batch = rejected
+		}
+
+		if !isTransientCode(st.Code()) || attempt == maxReportAttempts {
+			for _, ts := range batch {
+				msg, ok := errs[ts]
+				if !ok {
+					msg = st.Message()
+				}
+				ll.Warnf("rejected time series %s %v: %s", ts.Metric.Type, ts.Metric.Labels, msg)
+			}
+			return fmt.Errorf("failed to create %d time series after %d attempts: %v", len(batch), attempt, err)
+		}
+
+		delay := b.next()
+		ll.Warnf("failed to create %d time series (attempt %d), retrying in %v: %v", len(batch), attempt, delay, err)
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
 	}
+}
+
+// isTransientCode reports whether a CreateTimeSeries call failing with code
+// may succeed when retried.
+func isTransientCode(code codes.Code) bool {
+	switch code {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted:
+		return true
+	}
+
+	return false
+}
 
-	zap.S().Debugf("successfully reported %d time series in %v", len(batch), time.Since(start))
+// createTimeSeriesSummary returns the summary attached to a failed
+// CreateTimeSeries call, if any.
+func createTimeSeriesSummary(st *status.Status) *monitoringpb.CreateTimeSeriesSummary {
+	for _, d := range st.Details() {
+		if summary, ok := d.(*monitoringpb.CreateTimeSeriesSummary); ok {
+			return summary
+		}
+	}
 
 	return nil
 }
+
+// timeSeriesIndexRe matches the references to rejected time series in the
+// error message of CreateTimeSeries, e.g. "timeSeries[12]", "timeSeries[3,7]"
+// or "timeSeries[0-4]".
+var timeSeriesIndexRe = regexp.MustCompile(`timeSeries\[([\d,\- ]+)\]`)
+
+// timeSeriesIndexes returns the indexes in a list of a timeSeries reference.
+func timeSeriesIndexes(list string) []int {
+	var indexes []int
+	for _, item := range strings.Split(list, ",") {
+		from, to, isRange := strings.Cut(strings.TrimSpace(item), "-")
+		first, err := strconv.Atoi(from)
+		if err != nil {
+			continue
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(to); err != nil {
+				continue
+			}
+		}
+		// A batch has no more than timeSeriesBatchSize series
+		for i := first; i <= last && i < first+timeSeriesBatchSize; i++ {
+			indexes = append(indexes, i)
+		}
+	}
+
+	return indexes
+}
+
+// rejectedTimeSeries returns the time series of batch that the error message
+// reports as rejected.
+func rejectedTimeSeries(st *status.Status, batch []*monitoringpb.TimeSeries) []*monitoringpb.TimeSeries {
+	seen := map[int]bool{}
+	var rejected []*monitoringpb.TimeSeries
+	for _, m := range timeSeriesIndexRe.FindAllStringSubmatch(st.Message(), -1) {
+		for _, i := range timeSeriesIndexes(m[1]) {
+			if i >= len(batch) || seen[i] {
+				continue
+			}
+			seen[i] = true
+			rejected = append(rejected, batch[i])
+		}
+	}
+
+	return rejected
+}
+
+// timeSeriesErrorPrefix starts the error message of CreateTimeSeries when
+// some time series were rejected.
+const timeSeriesErrorPrefix = "One or more TimeSeries could not be written:"
+
+// timeSeriesIndexErrors returns the error of every time series the message
+// of a failed CreateTimeSeries call refers to, keyed by its index in the
+// request. The errors are separated by semicolons, each naming the series it
+// is about.
+func timeSeriesIndexErrors(message string) map[int]string {
+	message = strings.TrimSpace(strings.TrimPrefix(message, timeSeriesErrorPrefix))
+
+	errs := map[int]string{}
+	for _, part := range strings.Split(message, ";") {
+		part = strings.TrimSpace(part)
+		for _, m := range timeSeriesIndexRe.FindAllStringSubmatch(part, -1) {
+			for _, i := range timeSeriesIndexes(m[1]) {
+				if _, ok := errs[i]; !ok {
+					errs[i] = part
+				}
+			}
+		}
+	}
+
+	return errs
+}
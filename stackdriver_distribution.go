@@ -0,0 +1,180 @@
+package fastlystats
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"google.golang.org/genproto/googleapis/api/distribution"
+	"google.golang.org/genproto/googleapis/api/metric"
+	monitoringpb "google.golang.org/genproto/googleapis/monitoring/v3"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// distributionBuckets are powers of two from 1 to 2^63, which covers every
+// uint64 rate Fastly reports.
+const distributionBuckets = 64
+
+var distributionBucketOptions = &distribution.Distribution_BucketOptions{
+	Options: &distribution.Distribution_BucketOptions_ExponentialBuckets{
+		ExponentialBuckets: &distribution.Distribution_BucketOptions_Exponential{
+			NumFiniteBuckets: distributionBuckets,
+			GrowthFactor:     2,
+			Scale:            1,
+		},
+	},
+}
+
+// distributionMetricType is the metric type of the distribution of a field.
+func distributionMetricType(name string) string {
+	return fmt.Sprintf("custom.googleapis.com/fastly/%s_distribution", name)
+}
+
+// distributionDescriptor returns the descriptor for the distribution of the
+// per-second values of the field described by m.
+func distributionDescriptor(m *metric.MetricDescriptor) *metric.MetricDescriptor {
+	return &metric.MetricDescriptor{
+		Name:        fmt.Sprintf("%s_distribution", m.Name),
+		Type:        distributionMetricType(m.Name),
+		MetricKind:  metric.MetricDescriptor_GAUGE,
+		ValueType:   metric.MetricDescriptor_DISTRIBUTION,
+		Unit:        m.Unit,
+		Description: fmt.Sprintf("Distribution of the per-second values within each interval. %s", m.Description),
+		DisplayName: fmt.Sprintf("%s Distribution", m.DisplayName),
+		Labels:      metricLabels,
+	}
+}
+
+// distributionValue converts d to a Stackdriver distribution with
+// exponential buckets.
+func distributionValue(d *Distribution) *monitoringpb.TypedValue {
+	counts := make([]int64, distributionBuckets+2)
+	for _, v := range d.Samples {
+		counts[exponentialBucket(v)]++
+	}
+
+	return &monitoringpb.TypedValue{
+		Value: &monitoringpb.TypedValue_DistributionValue{
+			DistributionValue: &distribution.Distribution{
+				Count:                 int64(d.Count),
+				Mean:                  d.Mean,
+				SumOfSquaredDeviation: d.SumOfSquaredDeviation,
+				Range: &distribution.Distribution_Range{
+					Min: d.Min,
+					Max: d.Max,
+				},
+				BucketOptions: distributionBucketOptions,
+				BucketCounts:  counts,
+			},
+		},
+	}
+}
+
+// exponentialBucket returns the index of the bucket v falls in. Bucket 0 is
+// the underflow bucket for values below 1, and bucket i holds values in
+// [2^(i-1), 2^i).
+func exponentialBucket(v float64) int {
+	if v < 1 {
+		return 0
+	}
+
+	i := int(math.Floor(math.Log2(v))) + 1
+	if i > distributionBuckets+1 {
+		return distributionBuckets + 1
+	}
+
+	return i
+}
+
+// distributionTimeSeries builds a time series for every distribution of
+// meanStats.
+func (s *StackdriverExporter) distributionTimeSeries(meanStats *FastlyMeanStats) []*monitoringpb.TimeSeries {
+	end := timestamppb.New(time.Unix(int64(meanStats.IntervalEnd)+1, 0))
+
+	result := make([]*monitoringpb.TimeSeries, 0, len(meanStats.Distributions))
+	for name, d := range meanStats.Distributions {
+		result = append(result, &monitoringpb.TimeSeries{
+			Metric: &metric.Metric{
+				Type: distributionMetricType(name),
+			},
+			MetricKind: metric.MetricDescriptor_GAUGE,
+			ValueType:  metric.MetricDescriptor_DISTRIBUTION,
+			Points: []*monitoringpb.Point{{
+				Interval: &monitoringpb.TimeInterval{
+					StartTime: end,
+					EndTime:   end,
+				},
+				Value: distributionValue(d),
+			}},
+		})
+	}
+
+	return result
+}
+
+// missHistogramTimeSeries builds the miss latency distribution, with the
+// histogram bounds as explicit buckets, and the percentile gauges.
+func (s *StackdriverExporter) missHistogramTimeSeries(meanStats *FastlyMeanStats) []*monitoringpb.TimeSeries {
+	h := meanStats.MissHistogram
+	if h == nil {
+		return nil
+	}
+
+	end := timestamppb.New(time.Unix(int64(meanStats.IntervalEnd)+1, 0))
+	interval := &monitoringpb.TimeInterval{
+		StartTime: end,
+		EndTime:   end,
+	}
+
+	// The explicit bucket i is [bounds[i-1], bounds[i]), so the count of every
+	// histogram bucket goes in the bucket it is the upper bound of, leaving
+	// the overflow bucket empty.
+	counts := make([]int64, len(h.Counts)+1)
+	for i, c := range h.Counts {
+		counts[i] = int64(c)
+	}
+
+	result := []*monitoringpb.TimeSeries{{
+		Metric: &metric.Metric{
+			Type: "custom.googleapis.com/fastly/miss_histogram",
+		},
+		MetricKind: metric.MetricDescriptor_GAUGE,
+		ValueType:  metric.MetricDescriptor_DISTRIBUTION,
+		Points: []*monitoringpb.Point{{
+			Interval: interval,
+			Value: &monitoringpb.TypedValue{
+				Value: &monitoringpb.TypedValue_DistributionValue{
+					DistributionValue: &distribution.Distribution{
+						Count:                 int64(h.Total),
+						Mean:                  h.Mean(),
+						SumOfSquaredDeviation: h.SumOfSquaredDeviation(),
+						BucketOptions: &distribution.Distribution_BucketOptions{
+							Options: &distribution.Distribution_BucketOptions_ExplicitBuckets{
+								ExplicitBuckets: &distribution.Distribution_BucketOptions_Explicit{
+									Bounds: h.Bounds,
+								},
+							},
+						},
+						BucketCounts: counts,
+					},
+				},
+			},
+		}},
+	}}
+
+	for _, p := range missLatencyPercentiles {
+		result = append(result, &monitoringpb.TimeSeries{
+			Metric: &metric.Metric{
+				Type: fmt.Sprintf("custom.googleapis.com/fastly/miss_latency_p%g", p),
+			},
+			MetricKind: metric.MetricDescriptor_GAUGE,
+			ValueType:  metric.MetricDescriptor_DOUBLE,
+			Points: []*monitoringpb.Point{{
+				Interval: interval,
+				Value:    &monitoringpb.TypedValue{Value: &monitoringpb.TypedValue_DoubleValue{DoubleValue: h.Percentile(p)}},
+			}},
+		})
+	}
+
+	return result
+}
@@ -0,0 +1,81 @@
+package fastlystats
+
+import (
+	"testing"
+	"time"
+
+	monitoringpb "google.golang.org/genproto/googleapis/monitoring/v3"
+)
+
+func TestTimeSeriesIndexErrors(t *testing.T) {
+	message := "One or more TimeSeries could not be written: " +
+		"Points must be written in order. One or more of the points specified had an older start time than the most recent point.: timeSeries[0,3]; " +
+		"Field timeSeries[5].points[0].value had an invalid value: timeSeries[5]"
+
+	errs := timeSeriesIndexErrors(message)
+
+	outOfOrder := "Points must be written in order. One or more of the points specified had an older start time than the most recent point.: timeSeries[0,3]"
+	want := map[int]string{
+		0: outOfOrder,
+		3: outOfOrder,
+		5: "Field timeSeries[5].points[0].value had an invalid value: timeSeries[5]",
+	}
+	for i, msg := range want {
+		if errs[i] != msg {
+			t.Errorf("series %d: got %q, want %q", i, errs[i], msg)
+		}
+	}
+	if _, ok := errs[1]; ok {
+		t.Errorf("got an error for series 1, which was not rejected")
+	}
+
+	if errs := timeSeriesIndexErrors("Bad points: timeSeries[7-9]"); len(errs) != 3 || errs[8] == "" {
+		t.Errorf("got %v for a range, want series 7 to 9", errs)
+	}
+
+	if errs := timeSeriesIndexErrors("Internal error"); len(errs) != 0 {
+		t.Errorf("got %v without series references, want none", errs)
+	}
+}
+
+func TestTimeSeriesDeltaIntervals(t *testing.T) {
+	for _, kind := range []CounterKind{CounterDelta, CounterCumulative} {
+		t.Run(string(kind), func(t *testing.T) {
+			s := &StackdriverExporter{
+				opts:       ExportOptions{CounterKind: kind},
+				cumulative: map[string]*cumulativeSeries{},
+			}
+
+			// Consecutive snapshots, as published by a provider or the
+			// backfill, or the first snapshot after resuming from a
+			// checkpoint at 1000
+			var previous *monitoringpb.TimeInterval
+			for _, interval := range [][2]uint64{{1001, 1015}, {1016, 1030}} {
+				snapshot := &FastlyMeanStats{
+					Service:       "service",
+					IntervalStart: interval[0],
+					IntervalEnd:   interval[1],
+					Values:        StatValues{"requests": 10},
+					Totals:        StatValues{"requests": 150},
+				}
+				series := s.timeSeries(snapshot, "")
+				if len(series) != 1 {
+					t.Fatalf("got %d series, want 1", len(series))
+				}
+				current := series[0].Points[0].Interval
+
+				// The previous point ended at the checkpoint
+				previousEnd := time.Unix(1001, 0)
+				if previous != nil {
+					previousEnd = previous.EndTime.AsTime()
+				}
+				if kind == CounterDelta || previous == nil {
+					if start := current.StartTime.AsTime(); start.Sub(previousEnd) < time.Millisecond {
+						t.Errorf("interval %v starts at %v, less than 1ms after the previous end %v", interval, start, previousEnd)
+					}
+				}
+				previous = current
+			}
+		})
+	}
+}